@@ -0,0 +1,389 @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"math"
+	"path/filepath"
+	"screencastinator/scriptedit"
+	"strings"
+)
+
+// command is a mode of screencastinator, edit being the default one
+type command struct {
+	name string
+	args string // as displayed in the usage
+	help string
+	run  func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"edit", "recording", "open the interactive editor (default)", edit},
+		{"record", "[options] output", "record a shell session in a new pseudo-terminal", record},
+		{"play", "[options] recording", "replay the recording in the terminal, [space] pauses, [n] steps, [<] [>] seek, [+] [-] change the speed", play},
+		{"info", "recording", "describe the recording", info},
+		{"cut", "-from seconds -to seconds [-o output] recording", "remove what is played between two times", cut},
+		{"trim", "[-start seconds] [-end seconds] [-o output] recording", "only keep what is played between two times", trim},
+		{"speed", "-factor factor [-from seconds] [-to seconds] [-o output] recording", "play the recording, or a part of it, faster or slower", speed},
+		{"paste", "[-from seconds] [-to seconds] [-at seconds] [-o output] source recording", "copy a part of source in the recording", paste},
+		{"typing", "[-interval seconds] [-jitter fraction] [-seed seed] [-o output] recording", "type at a steady pace", typing},
+		{"idle", "-max seconds | -threshold seconds -factor factor [-o output] recording", "shorten the long pauses", idle},
+		{"export", "[-palette colours] [-title title] recording output", "write the recording as an asciicast, GIF, SVG or HTML player", export},
+		{"convert", "recording output", "convert between the asciicast and the session/timing formats", convert},
+		{"validate", "recording...", "check that recordings are consistent", validate},
+		{"edits", "project", "list the edits of a .edl project", edits},
+		{"revert", "-edit number project", "remove an edit from a .edl project", revert},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	c := findCommand(name)
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n\n%s\n\n", os.Args[0], c.name, c.args, c.help)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// loadRecording loads the recording for the commands that do not need a terminal, warnings go to stderr
+func loadRecording(filename string) error {
+	err := load(filename)
+	if _, malformed := err.(*scriptedit.SyntaxError); malformed {
+		fmt.Fprintf(os.Stderr, "%s: warning: %s\n", filename, err)
+		return nil
+	}
+	return err
+}
+
+// writeOutput writes the modified recording to output, or back where it was loaded from
+func writeOutput(output string) error {
+	if output == "" && projectFilename != "" {
+		return saveProject() // the edit is added to the project
+	}
+	if output == "" {
+		return saveRecording()
+	}
+	return writeFile(output)
+}
+
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", "", "write the result to `output`, a .cast file, a .edl project or a basefilename, instead of replacing the recording (kept as .bak.N)")
+}
+
+func info(args []string) error {
+	flags := newFlagSet("info")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	format, files := "session and timing", baseFilename + ".session " + baseFilename + ".timing"
+	if castFilename != "" {
+		format, files = "asciicast v2", castFilename
+	}
+	var size, unknown int
+	for _, ansi := range editorState.Content {
+		size += len(ansi.String())
+		if ansi.IsUnknown() {
+			unknown++
+		}
+	}
+	width, height := editorState.TerminalSize()
+	fmt.Printf("Files:     %s\n", files)
+	fmt.Printf("Format:    %s\n", format)
+	if projectFilename != "" {
+		fmt.Printf("Project:   %s, %d edits\n", projectFilename, len(editorState.Operations()))
+	}
+	if castHeader.Title != "" {
+		fmt.Printf("Title:     %s\n", castHeader.Title)
+	}
+	fmt.Printf("Terminal:  %dx%d\n", width, height)
+	fmt.Printf("Duration:  %.2f s\n", editorState.Total_time)
+	fmt.Printf("Chunks:    %d\n", len(editorState.Timings))
+	fmt.Printf("Bytes:     %d\n", size)
+	fmt.Printf("Commands:  %d (%d unknown sequences)\n", len(editorState.Content), unknown)
+	fmt.Printf("Chapters:  %d\n", len(editorState.Markers))
+	for _, marker := range editorState.Markers {
+		fmt.Printf("  %8.2f s %s\n", editorState.MarkerTime(marker), marker.Label)
+	}
+	return nil
+}
+
+func cut(args []string) error {
+	flags := newFlagSet("cut")
+	from := flags.Float64("from", -1, "start of the cut, in `seconds`")
+	to := flags.Float64("to", -1, "end of the cut, in `seconds`")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *from < 0 || *to <= *from {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	if !editorState.DeleteRegion(editorState.TimeToPosition(float32(*from)), editorState.TimeToPosition(float32(*to))) {
+		return fmt.Errorf("nothing to cut between %g and %g s", *from, *to)
+	}
+	return writeOutput(*output)
+}
+
+func trim(args []string) error {
+	flags := newFlagSet("trim")
+	start := flags.Float64("start", 0, "what is played before these `seconds` is removed")
+	end := flags.Float64("end", math.Inf(1), "what is played from these `seconds` is removed")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *end <= *start {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	trimmed := editorState.DeleteRegion(editorState.TimeToPosition(float32(*end)), len(editorState.Content))
+	if editorState.DeleteRegion(0, editorState.TimeToPosition(float32(*start))) {
+		trimmed = true
+	}
+	if !trimmed {
+		return fmt.Errorf("nothing to trim before %g s or from %g s", *start, *end)
+	}
+	return writeOutput(*output)
+}
+
+func speed(args []string) error {
+	flags := newFlagSet("speed")
+	factor := flags.Float64("factor", 0, "2 plays twice as fast, 0.5 twice as slow")
+	start := flags.Float64("from", 0, "only change what is played after these `seconds`")
+	end := flags.Float64("to", math.Inf(1), "only change what is played before these `seconds`")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *factor <= 0 || *end <= *start {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	if *start == 0 && math.IsInf(*end, 1) {
+		editorState.Speed(float32(*factor))
+	} else {
+		editorState.SpeedRegion(editorState.TimeToPosition(float32(*start)), editorState.TimeToPosition(float32(*end)), float32(*factor))
+	}
+	return writeOutput(*output)
+}
+
+func idle(args []string) error {
+	flags := newFlagSet("idle")
+	max := flags.Float64("max", 0, "cap the pauses to these `seconds`")
+	threshold := flags.Float64("threshold", 0, "with -factor, only scale what is above these `seconds`")
+	factor := flags.Float64("factor", 0, "multiply the pauses above -threshold by this `factor`")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*max > 0) == (*factor > 0) || *factor < 0 || *threshold < 0 {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	var changed int
+	if *max > 0 {
+		changed = editorState.LimitIdle(float32(*max))
+	} else {
+		changed = editorState.ScaleIdle(float32(*threshold), float32(*factor))
+	}
+	fmt.Fprintf(os.Stderr, "%d pauses shortened, the recording now lasts %.2f s\n", changed, editorState.Total_time)
+	return writeOutput(*output)
+}
+
+func typing(args []string) error {
+	flags := newFlagSet("typing")
+	interval := flags.Float64("interval", 0.1, "`seconds` between two keystrokes")
+	jitter := flags.Float64("jitter", 0, "vary each interval randomly by up to this `fraction` of it, 0.3 looks human")
+	seed := flags.Int64("seed", 1, "`seed` of the random variations, the same seed gives the same result")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *interval < 0 || *jitter < 0 || *jitter > 1 {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	changed := editorState.RetimeTyping(float32(*interval), float32(*jitter), *seed)
+	fmt.Fprintf(os.Stderr, "%d keystrokes retimed, the recording now lasts %.2f s\n", changed, editorState.Total_time)
+	return writeOutput(*output)
+}
+
+func paste(args []string) error {
+	flags := newFlagSet("paste")
+	start := flags.Float64("from", 0, "beginning of the copied part of source, in `seconds`")
+	end := flags.Float64("to", math.Inf(1), "end of the copied part of source, in `seconds`")
+	at := flags.Float64("at", math.Inf(1), "where it is pasted in the recording, in `seconds`, at the end by default")
+	output := outputFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 || *end <= *start {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	clip := editorState.Copy(editorState.TimeToPosition(float32(*start)), editorState.TimeToPosition(float32(*end)))
+	if clip == nil {
+		return fmt.Errorf("nothing is played between %g and %g s in %s", *start, *end, flags.Arg(0))
+	}
+	if err := loadRecording(flags.Arg(1)); err != nil {
+		return err
+	}
+	editorState.Paste(editorState.TimeToPosition(float32(*at)), clip)
+	return writeOutput(*output)
+}
+
+func export(args []string) error {
+	flags := newFlagSet("export")
+	paletteName := flags.String("palette", "xterm", "`colours` of the GIF, SVG and HTML exports: xterm, vga, solarized or 18 comma separated hex colours, foreground, background and the 16 ANSI colours")
+	title := flags.String("title", "", "`title` of the asciicast or of the web page")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errUsage
+	}
+	var err error
+	if palette, err = scriptedit.ParsePalette(*paletteName); err != nil {
+		return err
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	if *title != "" {
+		castHeader.Title = *title
+	}
+	return exportFile(flags.Arg(1))
+}
+
+func convert(args []string) error {
+	flags := newFlagSet("convert")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errUsage
+	}
+	output := flags.Arg(1)
+	if extension := strings.ToLower(filepath.Ext(output)); extension != "" && extension != scriptedit.ASCIICAST_EXTENSION {
+		return fmt.Errorf("%s: convert writes a .cast file or a session and timing pair, see export", output)
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+	return writeFile(output)
+}
+
+// loadProjectArgument loads the project given as the only argument of a command
+func loadProjectArgument(flags *flag.FlagSet) error {
+	if flags.NArg() != 1 || !strings.HasSuffix(flags.Arg(0), scriptedit.PROJECT_EXTENSION) {
+		flags.Usage()
+		return errUsage
+	}
+	return loadRecording(flags.Arg(0))
+}
+
+func edits(args []string) error {
+	flags := newFlagSet("edits")
+	flags.Parse(args)
+	if err := loadProjectArgument(flags); err != nil {
+		return err
+	}
+	fmt.Printf("Edits of %s\n", sourceFilename())
+	for index, op := range editorState.Operations() {
+		fmt.Printf("%4d  %s\n", index+1, op)
+	}
+	return nil
+}
+
+// revert makes the edits of the project again without the removed one, the following ones
+// moved accordingly. It refuses when one of them depends on the removed edit.
+func revert(args []string) error {
+	flags := newFlagSet("revert")
+	number := flags.Int("edit", 0, "`number` of the edit to remove, as listed by the edits command")
+	flags.Parse(args)
+	if err := loadProjectArgument(flags); err != nil {
+		return err
+	}
+	if *number < 1 || *number > len(editorState.Operations()) {
+		return fmt.Errorf("%s has no edit %d", flags.Arg(0), *number)
+	}
+	operations, err := editorState.Without(*number - 1)
+	if err != nil {
+		return fmt.Errorf("cannot remove edit %d: %s", *number, err)
+	}
+	markers := editorState.Markers
+	if err := load(sourceFilename()); err != nil {
+		if _, malformed := err.(*scriptedit.SyntaxError); !malformed {
+			return err
+		}
+	}
+	for index, op := range operations {
+		if err := editorState.Apply(op); err != nil {
+			if index+1 >= *number {
+				index++ // its number in the project
+			}
+			return fmt.Errorf("without edit %d, edit %d no longer applies: %s", *number, index+1, err)
+		}
+	}
+	editorState.Markers = markers
+	projectFilename = flags.Arg(0)
+	return saveProject()
+}
+
+func validate(args []string) error {
+	flags := newFlagSet("validate")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	invalid := 0
+	for _, filename := range flags.Args() {
+		problems := []string{}
+		err := load(filename)
+		if _, malformed := err.(*scriptedit.SyntaxError); err != nil && !malformed {
+			fmt.Printf("%s: %s\n", filename, err)
+			invalid++
+			continue
+		} else if err != nil {
+			problems = append(problems, err.Error())
+		}
+		problems = append(problems, editorState.Validate()...)
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", filename)
+			continue
+		}
+		invalid++
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", filename, problem)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid recordings", invalid)
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"runtime/debug"
+	"time"
+	"unicode/utf8"
+	"screencastinator/scriptedit"
+)
+
+const ESC = scriptedit.ESC
+const ESC_CHR = scriptedit.ESC_CHR
+
+// const RESTORE = ESC + "[20h" + ESC + "[8m"
+
+
+// keys
+const UP byte = 'A'
+const DOWN byte = 'B'
+const FORWARD byte = 'C'
+const BACK byte = 'D'
+
+const CTRL_PREFIX = "1;5"
+
+const IDLE_LIMIT = 2 // seconds, the longest pause kept by [l]
+const DELAY_STEP = 0.1 // seconds added or removed by [ and ]
+const TYPING_INTERVAL = 0.1 // seconds between the characters typed in insert mode
+const ESC_TIMEOUT = 50 * time.Millisecond // the longest wait for the rest of an escape sequence
+
+var (
+	orig_termios scriptedit.Termios
+	new_termios scriptedit.Termios
+	ttyfd scriptedit.TTY = 0 // STDIN_FILENO
+)
+
+// edit opens the interactive editor
+func edit(args []string) (err error) {
+	flags := newFlagSet("edit")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	err = load(flags.Arg(0))
+	if _, malformed := err.(*scriptedit.SyntaxError); malformed {
+		loadWarning = fmt.Sprintf("Warning: %s", err)
+	} else if err != nil {
+		return err
+	}
+
+	err = ttyfd.GetTermios(&orig_termios)
+	if err != nil {
+		return fmt.Errorf("GetTermios fluked %s", err)
+	}
+
+	defer ttyfd.SetTermios(&orig_termios)
+	new_termios = orig_termios
+	err = ttyfd.Tty_raw(&new_termios)
+	if err != nil {
+		return fmt.Errorf("Tty_raw fluked %s", err)
+	}
+	defer func() {
+		// a crash must give the terminal back, the journal keeps the edits
+		if r := recover(); r != nil {
+			ttyfd.Restore()
+			ttyfd.SetTermios(&orig_termios)
+			reopen := flags.Arg(0)
+			if projectFilename != "" {
+				reopen = projectFilename
+			}
+			journal := journalFilename()
+			closeJournal(true)
+			err = fmt.Errorf("screencastinator crashed: %v\nOpen %s again to recover the edits kept in %s\n\n%s", r, reopen, journal, debug.Stack())
+		}
+	}()
+	err = mainLoop()
+	closeJournal(editorState.Dirty)
+	return err
+}
+
+// readKeys sends the keys typed on the terminal, the channel is closed when it can no longer be read.
+func readKeys() chan byte {
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		for {
+			chr, n, err := ttyfd.Readchr()
+			if err != nil || n == 0 {
+				return
+			}
+			keys <- chr
+		}
+	}()
+	return keys
+}
+
+// readEscape reads what follows an ESC, the rest of a CSI or SS3 sequence like an arrow key is dropped.
+// It returns false for a bare ESC, when nothing follows it right away.
+func readEscape(keys chan byte) bool {
+	timeout := time.After(ESC_TIMEOUT)
+	next := func() (byte, bool) {
+		select {
+		case chr, ok := <-keys:
+			return chr, ok
+		case <-timeout:
+			return 0, false
+		}
+	}
+	chr, ok := next()
+	switch {
+	case !ok:
+		return false
+	case chr == 'O': // SS3, one more byte
+		next()
+	case chr == '[':
+		for chr, ok = next(); ok && chr >= 0x20 && chr < 0x40; chr, ok = next() {
+			// parameters and intermediates, up to the final byte
+		}
+	}
+	return true
+}
+
+// prompt asks for a value on the status line, it returns false when ESC is pressed.
+func prompt(question string, keys chan byte) (string, bool) {
+	var answer []byte
+	for {
+		ttyfd.Notify(fmt.Sprintf("%s %s ", question, answer))
+		chr := <-keys
+		switch {
+		case chr == '\r' || chr == '\n':
+			return string(answer), true
+		case chr == ESC_CHR && readEscape(keys):
+			continue // not handled in the answer
+		case chr == ESC_CHR || chr == 3 || chr == 0: // CTRL + C
+			return "", false
+		case chr == 127 || chr == '\b':
+			if len(answer) > 0 {
+				answer = answer[:len(answer)-1]
+			}
+		case chr >= ' ' && chr < 127:
+			answer = append(answer, chr)
+		}
+	}
+}
+
+// insertMode types the keys in the recording at the Position until ESC is pressed.
+// Backspace only removes what has been typed.
+func insertMode(keys chan byte) {
+	typed := 0
+	for {
+		ttyfd.Notify("-- INSERT -- type the new text, [ESC] to leave")
+		var text []byte
+		chr := <-keys
+		switch {
+		case chr == ESC_CHR && readEscape(keys):
+			continue // the arrows do not move in the insert mode
+		case chr == ESC_CHR || chr == 0:
+			ttyfd.WriteStatus(&editorState)
+			return
+		case chr == 127 || chr == '\b':
+			if typed > 0 {
+				editorState.Position--
+				editorState.DeleteRegion(editorState.Position, editorState.Position+1)
+				typed--
+				ttyfd.Redraw(&editorState)
+			}
+			continue
+		case chr == '\r':
+			text = []byte("\r\n")
+		case chr < ' ':
+			continue
+		default:
+			text = append(text, chr)
+			for !utf8.FullRune(text) {
+				text = append(text, <-keys)
+			}
+		}
+		before := len(editorState.Content)
+		if editorState.InsertText(editorState.Position, string(text), TYPING_INTERVAL) {
+			typed += len(editorState.Content) - before
+			ttyfd.Redraw(&editorState)
+		}
+	}
+}
+
+func mainLoop() error {
+	ttyfd.Init()
+	ttyfd.WriteStatus(&editorState)
+	if loadWarning != "" {
+		ttyfd.Notify(loadWarning)
+	}
+
+	keys := readKeys()
+	readchr := func() byte {
+		return <-keys
+	}
+	if err := openJournal(readchr); err != nil {
+		ttyfd.Notify(fmt.Sprintf("No journal, the edits will be lost in a crash: %s", err))
+	}
+	var scheduler *scriptedit.Scheduler
+	stopPlaying := func() {
+		scheduler.Stop()
+		scheduler = nil
+		ttyfd.Redraw(&editorState) // the last chunk may end in the middle of a command
+	}
+out:
+	for {
+		var chunks chan scriptedit.Chunk
+		if scheduler != nil {
+			chunks = scheduler.Chunks
+		}
+		var chr byte
+		select {
+		case chunk, ok := <-chunks:
+			if !ok { // the end of the recording
+				if err := scheduler.Err(); err != nil {
+					ttyfd.Redraw(&editorState)
+					ttyfd.Notify(err.Error())
+				} else {
+					ttyfd.WriteStatus(&editorState)
+				}
+				scheduler = nil
+			} else {
+				ttyfd.Play(&editorState, chunk)
+			}
+			continue out
+		case key, ok := <-keys:
+			if !ok {
+				break out
+			}
+			chr = key
+		}
+		if scheduler != nil && chr != ' ' {
+			stopPlaying() // the keys work on a still position
+		}
+
+		switch chr {
+		case ESC_CHR:
+			chr = readchr()
+			if chr == '[' {
+				chr = readchr()
+
+				switch chr {
+				case '1':
+					chr = readchr()
+					if (chr == ';') {
+						chr = readchr()
+						if (chr == '5') {
+							chr = readchr()
+							switch chr {   // this is a CTRL + ARROW
+							case BACK:
+								if editorState.PreviousTiming() {
+									ttyfd.Redraw(&editorState)
+								}
+							case FORWARD:
+								if editorState.NextTiming() {
+									ttyfd.Redraw(&editorState)
+								}
+							}  }
+
+					}
+				case '3':
+					chr = readchr()
+					if (chr == '~') { // this is DEL
+						if editorState.In == -1 {
+							editorState.DeleteRegion(editorState.Position, editorState.Position + 1)
+							ttyfd.WriteStatus(&editorState) // It should not change the screen
+						} else {
+							editorState.DeleteRegion(editorState.In, editorState.Out)
+							if editorState.Position != editorState.In {
+								editorState.Position = editorState.In
+								editorState.In = -1
+								editorState.Out = -1
+								ttyfd.Redraw(&editorState)
+							} else {
+								editorState.In = -1
+								editorState.Out = -1
+								ttyfd.WriteStatus(&editorState) // It should not change the screen
+							}
+						}
+
+					}
+				case BACK:
+					if editorState.Previous() {
+						ttyfd.Redraw(&editorState)
+					}
+				case FORWARD:
+					if editorState.Next() {
+						ttyfd.Redraw(&editorState)
+					}
+
+				}
+			} else if chr == ESC_CHR {
+				editorState.Out = -1
+				editorState.In = -1
+				ttyfd.Redraw(&editorState)
+			}
+
+		case 'i':
+			editorState.In = editorState.Position
+			if editorState.Out < editorState.In {
+				editorState.Out = editorState.In + 1
+			}
+			ttyfd.Redraw(&editorState)
+
+		case 'o':
+			editorState.Out = editorState.Position
+			if editorState.Out < editorState.In {
+				editorState.Out = editorState.In + 1
+			}
+
+			ttyfd.Redraw(&editorState)
+
+		case 'n':
+			if (editorState.In == -1) {
+				editorState.In = editorState.Position
+			}
+			result := ttyfd.JumpToNextSameCursorPosition(&editorState)
+			if result {
+				editorState.Out = editorState.Position
+				ttyfd.WriteStatus(&editorState)
+			} else {
+				ttyfd.Redraw(&editorState)
+			}
+
+		case 'u':
+			if editorState.Undo() {
+				editorState.In = -1
+				editorState.Out = -1
+				ttyfd.Redraw(&editorState)
+			} else {
+				ttyfd.Notify("Nothing to undo")
+			}
+		case 'r':
+			if editorState.Redo() {
+				editorState.In = -1
+				editorState.Out = -1
+				ttyfd.Redraw(&editorState)
+			} else {
+				ttyfd.Notify("Nothing to redo")
+			}
+		case 'm':
+			if editorState.ToggleMarker(editorState.Position) {
+				ttyfd.Notify("Chapter added")
+			} else {
+				ttyfd.Notify("Chapter removed")
+			}
+		case 'l':
+			changed := editorState.LimitIdle(IDLE_LIMIT)
+			ttyfd.WriteStatus(&editorState)
+			ttyfd.Notify(fmt.Sprintf("%d pauses limited to %d s", changed, IDLE_LIMIT))
+		case '+', '-':
+			factor := float32(2)
+			if chr == '-' {
+				factor = 0.5
+			}
+			if editorState.In < 0 {
+				ttyfd.Notify("Select a region with [i] and [o] first")
+			} else if editorState.SpeedRegion(editorState.In, editorState.Out, factor) {
+				ttyfd.WriteStatus(&editorState)
+				if factor >= 1 {
+					ttyfd.Notify(fmt.Sprintf("Selection played %gx faster", factor))
+				} else {
+					ttyfd.Notify(fmt.Sprintf("Selection played %gx slower", 1/factor))
+				}
+			}
+		case 't', 'p':
+			question := "Delay of this chunk in seconds:"
+			if chr == 'p' {
+				question = "Pause to insert in seconds:"
+			}
+			answer, ok := prompt(question, keys)
+			seconds, err := strconv.ParseFloat(answer, 32)
+			if !ok {
+				ttyfd.WriteStatus(&editorState)
+			} else if err != nil || seconds < 0 {
+				ttyfd.Notify(fmt.Sprintf("Invalid number of seconds '%s'", answer))
+			} else {
+				if chr == 'p' {
+					editorState.InsertPause(editorState.Position, float32(seconds))
+				} else {
+					editorState.SetDelay(editorState.CurrentTiming(), float32(seconds))
+				}
+				ttyfd.WriteStatus(&editorState)
+			}
+		case '[', ']', 'z':
+			index := editorState.CurrentTiming()
+			if index < 0 {
+				ttyfd.Notify("No timing here")
+				break
+			}
+			delay := float32(0)
+			if chr == '[' {
+				delay = editorState.Timings[index].Time - DELAY_STEP
+			} else if chr == ']' {
+				delay = editorState.Timings[index].Time + DELAY_STEP
+			}
+			if editorState.SetDelay(index, delay) {
+				ttyfd.WriteStatus(&editorState)
+			}
+		case 'c', 'x':
+			if editorState.In < 0 {
+				ttyfd.Notify("Select a region with [i] and [o] first")
+			} else if chr == 'c' {
+				editorState.Copy(editorState.In, editorState.Out)
+				ttyfd.Notify("Selection copied")
+			} else if editorState.Cut(editorState.In, editorState.Out) != nil {
+				editorState.In = -1
+				editorState.Out = -1
+				ttyfd.Redraw(&editorState)
+			}
+		case 'v':
+			if editorState.Paste(editorState.Position, editorState.Clipboard) {
+				editorState.In = -1
+				editorState.Out = -1
+				ttyfd.Redraw(&editorState)
+			} else {
+				ttyfd.Notify("Nothing to paste")
+			}
+		case 'a':
+			insertMode(keys)
+		case '?', 'h':
+			ttyfd.Help()
+			readchr()
+			ttyfd.Redraw(&editorState)
+		case 'q':
+			if !editorState.Dirty {
+				break out
+			}
+			ttyfd.Notify("Unsaved edits, press [q] again to quit without saving them")
+			if readchr() == 'q' {
+				editorState.Dirty = false // they are given up
+				break out
+			}
+			ttyfd.WriteStatus(&editorState)
+		case ' ':
+			if scheduler == nil {
+				player := scriptedit.NewPlayer(&editorState)
+				player.SeekPosition(editorState.Position)
+				scheduler = scriptedit.NewScheduler(player)
+			} else {
+				stopPlaying()
+			}
+		case 's':
+			if err := saveProject(); err != nil {
+				ttyfd.Notify(err.Error())
+			} else {
+				editorState.Dirty = false
+				resetJournal()
+				ttyfd.Notify("Edits saved in " + projectFilename)
+			}
+		case 'w':
+			target, ok := prompt("Save as (.edl project, .cast or basefilename):", keys)
+			if !ok || target == "" {
+				ttyfd.WriteStatus(&editorState)
+				break
+			}
+			if saved, err := saveAs(target); err != nil {
+				ttyfd.Notify(err.Error())
+			} else if saved {
+				editorState.Dirty = false
+				resetJournal()
+				ttyfd.Notify("Saved as " + target)
+			} else {
+				ttyfd.Notify("Exported to " + target)
+			}
+		default :
+			ttyfd.Notify(fmt.Sprintf("Unknown Key '%c' (%d)", chr, chr))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"bufio"
+	"io"
+	"io/ioutil"
+	"screencastinator/scriptedit"
+	"strings"
+	"path/filepath"
+)
+
+// load reads a recording: an asciicast file, the session and timing pair of a base name or a project.
+// A *scriptedit.SyntaxError is only a warning, the recording is loaded anyway.
+func load(filename string) error {
+	var err error
+	editorState = scriptedit.EditorState{}
+	castFilename, castHeader, projectFilename = "", scriptedit.AsciicastHeader{}, ""
+	baseFilename = filename
+	if strings.HasSuffix(filename, scriptedit.PROJECT_EXTENSION) {
+		err = loadProject(filename)
+	} else if strings.HasSuffix(filename, scriptedit.ASCIICAST_EXTENSION) {
+		castFilename = filename
+		baseFilename = strings.TrimSuffix(filename, scriptedit.ASCIICAST_EXTENSION)
+		err = loadAsciicast(castFilename)
+	} else {
+		for _, extension := range []string{".session", ".timing"} {
+			baseFilename = strings.TrimSuffix(baseFilename, extension)
+		}
+		err = loadScript(baseFilename + ".session", baseFilename + ".timing")
+	}
+	editorState.In = -1
+	editorState.Out = -1
+	editorState.Dirty = false
+	return err
+}
+
+// loadProject loads the source of an edit decision list and makes its edits again, they can be undone.
+func loadProject(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	project, err := scriptedit.ReadProject(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	source := project.Source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(filename), source)
+	}
+	warning := load(source)
+	if _, malformed := warning.(*scriptedit.SyntaxError); warning != nil && !malformed {
+		return warning
+	}
+	for index, op := range project.Edits {
+		if err := editorState.Apply(op); err != nil {
+			return fmt.Errorf("%s: edit %d: %s", filename, index+1, err)
+		}
+	}
+	if project.Markers != nil {
+		editorState.Markers = project.Markers
+	}
+	projectFilename = filename
+	return warning
+}
+
+func loadScript(sessionFilename string, timingFilename string) error {
+	file, err := os.Open(sessionFilename);
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	contentreader := bufio.NewReader(file)
+	header, _ := contentreader.ReadString('\n') // Kicks out the preliminary from script (This script has been started BLAHBLAH
+	editorState.Header = header
+	editorState.Width, editorState.Height = scriptedit.ParseScriptHeader(header)
+	var warning error
+	editorState.Content, warning = scriptedit.ParseANSI(contentreader)
+	if _, malformed := warning.(*scriptedit.SyntaxError); warning != nil && !malformed {
+		return warning
+	}
+
+	timings_file, err := os.Open(timingFilename);
+	if err != nil {
+		return err
+	}
+	timingsreader := bufio.NewReader(timings_file)
+	editorState.ParseTimings(timingsreader)
+	timings_file.Close()
+	return warning
+}
+
+func loadAsciicast(filename string) error {
+	file, err := os.Open(filename);
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := editorState.ParseAsciicast(bufio.NewReader(file))
+	if header != nil {
+		editorState.Header = header.ScriptHeader()
+		castHeader = *header
+	}
+	return err
+}
+
+// exportFile writes the recording in the format given by the extension of filename, an existing one is backed up
+func exportFile(filename string) error {
+	var write func(file *os.File) error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gif":
+		write = func(file *os.File) error { return editorState.WriteGIF(file, palette) }
+	case ".svg":
+		write = func(file *os.File) error { return editorState.WriteSVG(file, palette) }
+	case ".html", ".htm":
+		write = func(file *os.File) error { return editorState.WriteHTML(file, palette, castHeader.Title) }
+	case scriptedit.ASCIICAST_EXTENSION:
+		if castHeader.Env == nil {
+			castHeader.Env = map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")}
+		}
+		write = func(file *os.File) error { return editorState.WriteAsciicast(file, castHeader) }
+	default:
+		return fmt.Errorf("%s: unknown export format, use .cast, .gif, .svg or .html", filename)
+	}
+	if _, err := backup(filename); err != nil {
+		return err
+	}
+	return writeAtomically(filename, write)
+}
+
+// writeFile writes the recording to target, a session and timing pair if it has no known extension.
+func writeFile(target string) error {
+	switch strings.ToLower(filepath.Ext(target)) {
+	case scriptedit.ASCIICAST_EXTENSION, ".gif", ".svg", ".html", ".htm":
+		return exportFile(target)
+	case scriptedit.PROJECT_EXTENSION:
+		return writeProject(target)
+	}
+	return save(target + ".session", target + ".timing")
+}
+
+// saveAs writes the edited recording or project to target, which becomes the document being edited.
+// An export to another format is not: the edits are still to be saved.
+func saveAs(target string) (saved bool, err error) {
+	switch strings.ToLower(filepath.Ext(target)) {
+	case ".gif", ".svg", ".html", ".htm":
+		return false, exportFile(target)
+	case scriptedit.PROJECT_EXTENSION:
+		projectFilename = target
+		return true, saveProject()
+	}
+	if err = writeFile(target); err != nil {
+		return false, err
+	}
+	// the recording has the edits now, a project of the previous one no longer applies to it
+	projectFilename, castFilename, baseFilename = "", "", target
+	if strings.HasSuffix(target, scriptedit.ASCIICAST_EXTENSION) {
+		castFilename = target
+		baseFilename = strings.TrimSuffix(target, scriptedit.ASCIICAST_EXTENSION)
+	}
+	editorState.Forget()
+	return true, nil
+}
+
+// saveRecording writes the recording back in the format it was loaded from.
+func saveRecording() error {
+	if castFilename == "" {
+		return save(baseFilename + ".session", baseFilename + ".timing")
+	}
+	return exportFile(castFilename)
+}
+
+// saveProject writes the edits in the project of the recording, [basefilename].edl unless it was loaded from one.
+// The recording itself is not modified.
+// A project of the recording that has not been opened is not replaced, its edits would be lost.
+func saveProject() error {
+	if projectFilename == "" {
+		filename := baseFilename + scriptedit.PROJECT_EXTENSION
+		if _, err := os.Stat(filename); err == nil {
+			return fmt.Errorf("%s already exists, open it to go on with its edits or save as another project with [w]", filename)
+		}
+		projectFilename = filename
+	}
+	return writeProject(projectFilename)
+}
+
+// sourceFilename returns the recording that has been loaded, or that the project references.
+func sourceFilename() string {
+	if castFilename != "" {
+		return castFilename
+	}
+	return baseFilename
+}
+
+// writeProject writes the edits as an edit decision list referencing the loaded recording.
+func writeProject(target string) error {
+	source := sourceFilename()
+	absoluteSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	absoluteTarget, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	if relative, err := filepath.Rel(filepath.Dir(absoluteTarget), absoluteSource); err == nil {
+		source = relative
+	} else {
+		source = absoluteSource
+	}
+	if _, err := backup(target); err != nil {
+		return err
+	}
+	return writeAtomically(target, func(file *os.File) error { return editorState.WriteProject(file, source) })
+}
+
+// save writes the session and timing pair, the previous version is kept as a numbered backup.
+// Both files are complete before any of them replaces the previous version.
+func save(sessionFilename string, timingFilename string) error {
+	backups, err := backup(sessionFilename, timingFilename)
+	if err != nil {
+		return err
+	}
+	session, err := writeTemp(sessionFilename, func(file *os.File) error {
+		if _, err := file.WriteString(editorState.Header); err != nil {
+			return err
+		}
+		for _, ansi := range editorState.Content {
+			if _, err := file.WriteString(ansi.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	timing, err := writeTemp(timingFilename, func(file *os.File) error {
+		for _, entry := range editorState.Timings {
+			if _, err := fmt.Fprintf(file, "%f %d\n", entry.Time, entry.Length); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(session)
+		return err
+	}
+	if err = os.Rename(session, sessionFilename); err != nil {
+		os.Remove(session)
+		os.Remove(timing)
+		return err
+	}
+	if err = os.Rename(timing, timingFilename); err != nil {
+		os.Remove(timing)
+		// the session must not be left with the timing of another recording
+		if backups[0] != "" {
+			os.Rename(backups[0], sessionFilename)
+		} else {
+			os.Remove(sessionFilename)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeTemp writes a temporary file next to filename, with the permissions of filename if it exists.
+// It returns the name of the temporary file.
+func writeTemp(filename string, write func(file *os.File) error) (string, error) {
+	file, err := ioutil.TempFile(filepath.Dir(filename), "." + filepath.Base(filename) + ".")
+	if err != nil {
+		return "", err
+	}
+	var mode os.FileMode = 0644
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// writeAtomically replaces filename once it has been completely written, it is never left half written.
+func writeAtomically(filename string, write func(file *os.File) error) error {
+	temp, err := writeTemp(filename, write)
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(temp, filename); err != nil {
+		os.Remove(temp)
+	}
+	return err
+}
+
+// backup keeps a copy of the existing files as [filename].bak.N, the first N free for all of them.
+// The files stay in place until they are replaced.
+// It returns the name of the copy of each file, empty when the file does not exist.
+func backup(filenames ...string) ([]string, error) {
+	backups := make([]string, len(filenames))
+	var existing []string
+	for _, filename := range filenames {
+		if _, err := os.Stat(filename); err == nil {
+			existing = append(existing, filename)
+		}
+	}
+	if len(existing) == 0 {
+		return backups, nil // the first save of an imported recording has no original to back up
+	}
+	number := 1
+	for ; ; number++ {
+		free := true
+		for _, filename := range existing {
+			if _, err := os.Lstat(fmt.Sprintf("%s.bak.%d", filename, number)); err == nil {
+				free = false
+			}
+		}
+		if free {
+			break
+		}
+	}
+	for i, filename := range filenames {
+		target := fmt.Sprintf("%s.bak.%d", filename, number)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		if err := os.Link(filename, target); err != nil {
+			if err = copyFile(filename, target); err != nil {
+				return backups, err
+			}
+		}
+		backups[i] = target
+	}
+	return backups, nil
+}
+
+func copyFile(source, target string) error {
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(output, input)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
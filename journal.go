@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"screencastinator/scriptedit"
+)
+
+var journalFile *os.File // the edits of the editor that are not saved yet
+
+// journalFilename returns where the editor keeps the unsaved edits, next to what they apply to:
+// the project or, before it is saved, the recording.
+func journalFilename() string {
+	if projectFilename != "" {
+		return projectFilename + scriptedit.JOURNAL_EXTENSION
+	}
+	return sourceFilename() + scriptedit.JOURNAL_EXTENSION
+}
+
+// openJournal offers to replay the edits left by an editor that did not quit properly,
+// then writes the new edits after the ones that could be replayed.
+func openJournal(readchr func() byte) error {
+	filename := journalFilename()
+	var replayed bytes.Buffer // the journal of the edits made again
+	if file, err := os.Open(filename); err == nil {
+		operations, err := scriptedit.ReadJournal(file)
+		file.Close()
+		if len(operations) > 0 {
+			ttyfd.Notify(fmt.Sprintf("%d unsaved edits were left by a crash, replay them? [y/n]", len(operations)))
+			if readchr() == 'y' {
+				editorState.Journal = &replayed
+				for index, op := range operations {
+					if err = editorState.Apply(op); err != nil {
+						err = fmt.Errorf("edit %d and the %d following ones are dropped: %s", index+1, len(operations)-index-1, err)
+						break
+					}
+				}
+				editorState.Journal = nil
+			}
+			ttyfd.Redraw(&editorState)
+		}
+		if err != nil {
+			ttyfd.Notify(fmt.Sprintf("The journal %s is damaged: %s", filename, err))
+		}
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = replayed.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	journalFile = file
+	editorState.Journal = file
+	return nil
+}
+
+// resetJournal forgets the edits that have been saved, the next ones apply to the saved project.
+func resetJournal() {
+	if journalFile == nil {
+		return
+	}
+	journalFile.Truncate(0)
+	if filename := journalFilename(); filename != journalFile.Name() && os.Rename(journalFile.Name(), filename) == nil {
+		journalFile.Close()
+		journalFile, editorState.Journal = nil, nil
+		if file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			journalFile, editorState.Journal = file, file
+		}
+	}
+}
+
+// closeJournal removes the journal unless it still has unsaved edits.
+func closeJournal(keep bool) {
+	if journalFile == nil {
+		return
+	}
+	editorState.Journal = nil
+	journalFile.Close()
+	if !keep {
+		os.Remove(journalFile.Name())
+	}
+	journalFile = nil
+}
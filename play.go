@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"os"
+	"screencastinator/scriptedit"
+)
+
+const SEEK_STEP = 5 // seconds
+
+// play replays a recording like scriptreplay, with keys to control it when it runs in a terminal
+func play(args []string) error {
+	flags := newFlagSet("play")
+	speed := flags.Float64("speed", 1, "speed `factor` of the replay")
+	from := flags.Float64("from", 0, "start the replay at these `seconds`")
+	to := flags.Float64("to", math.Inf(1), "stop the replay at these `seconds`")
+	maxIdle := flags.Float64("max-idle", 0, "cap the pauses to these `seconds`")
+	loop := flags.Bool("loop", false, "replay the -from -to range until [q] is pressed")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *speed <= 0 || *to <= *from {
+		flags.Usage()
+		return errUsage
+	}
+	if err := loadRecording(flags.Arg(0)); err != nil {
+		return err
+	}
+
+	player := scriptedit.NewPlayer(&editorState)
+	player.Speed = float32(*speed)
+	player.MaxIdle = float32(*maxIdle)
+	if *from > 0 {
+		os.Stdout.Write(player.Seek(float32(*from)))
+	}
+
+	var keys chan byte
+	if ttyfd.GetTermios(&orig_termios) == nil {
+		new_termios = orig_termios
+		ttyfd.Tty_raw(&new_termios)
+		defer ttyfd.SetTermios(&orig_termios)
+		keys = readKeys()
+	}
+
+	now, paused, factor := player.Time(), false, player.Speed
+	played := false // something of the -from -to range, or the loop would go on without end
+	scheduler := scriptedit.NewScheduler(player)
+	for {
+		select {
+		case chunk, ok := <-scheduler.Chunks:
+			if ok && float64(chunk.Time) <= *to {
+				os.Stdout.Write(chunk.Data)
+				now, played = chunk.Time, true
+				continue
+			}
+			if ok {
+				scheduler.Stop()
+			} else if err := scheduler.Err(); err != nil {
+				return err
+			}
+			if !*loop || !played {
+				return nil
+			}
+			played = false
+			os.Stdout.Write(player.Seek(float32(*from)))
+			now = player.Time()
+			scheduler = scriptedit.NewScheduler(player)
+			if paused {
+				scheduler.Pause()
+			}
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil // stdin is over, only the replay goes on
+				continue
+			}
+			switch key {
+			case 'q', 3: // CTRL + C
+				scheduler.Stop()
+				return nil
+			case ' ':
+				if paused {
+					scheduler.Resume()
+				} else {
+					scheduler.Pause()
+				}
+				paused = !paused
+			case 'n':
+				scheduler.Step()
+			case '+':
+				factor *= 2
+				scheduler.SetSpeed(factor)
+			case '-':
+				factor /= 2
+				scheduler.SetSpeed(factor)
+			case '<':
+				scheduler.Seek(float32(math.Max(float64(now-SEEK_STEP), *from)))
+			case '>':
+				scheduler.Seek(now + SEEK_STEP)
+			}
+		}
+	}
+}
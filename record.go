@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"io"
+	"os/exec"
+	"os/signal"
+	"screencastinator/scriptedit"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// record runs a shell in a pseudo-terminal and saves what it displays
+func record(args []string) error {
+	flags := newFlagSet("record")
+	command := flags.String("command", "", "`command line` to record instead of an interactive $SHELL")
+	size := flags.String("size", "", "`columns`x`lines` of the recorded terminal, the size of the current one by default")
+	input := flags.Bool("input", false, "also record the keystrokes in [output].input and [output].input.timing")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	output := flags.Arg(0)
+
+	width, height, err := ttyfd.GetWindowSize()
+	if err != nil || width == 0 || height == 0 {
+		width, height = scriptedit.WIDTH, scriptedit.HEIGHT
+	}
+	if *size != "" {
+		if _, err := fmt.Sscanf(*size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+			return fmt.Errorf("invalid size %q, expected columns x lines like 132x43", *size)
+		}
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.Command(shell)
+	if *command != "" {
+		cmd = exec.Command("/bin/sh", "-c", *command)
+	}
+	cmd.Env = os.Environ()
+
+	master, err := scriptedit.StartInPty(cmd, width, height)
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+	fmt.Fprintf(os.Stderr, "Recording a %dx%d terminal to %s, exit the shell to stop.\n", width, height, output)
+
+	// the keys go to the recorded shell untouched
+	if ttyfd.GetTermios(&orig_termios) == nil {
+		new_termios = orig_termios
+		ttyfd.Tty_raw(&new_termios)
+		defer ttyfd.SetTermios(&orig_termios)
+	}
+
+	stdin, err := interruptibleStdin()
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+	screen := scriptedit.NewRecorder()
+	keys := scriptedit.NewRecorder()
+
+	// the recorded terminal follows the size of the real one, the recording keeps the largest
+	resized := make(chan os.Signal, 1)
+	followed := make(chan bool)
+	largestWidth, largestHeight := width, height
+	if *size == "" {
+		signal.Notify(resized, syscall.SIGWINCH)
+	}
+	go func() {
+		defer close(followed)
+		pty := scriptedit.TTY(master.Fd())
+		currentWidth, currentHeight := width, height
+		for _ = range resized {
+			newWidth, newHeight, err := ttyfd.GetWindowSize()
+			if err != nil || newWidth <= 0 || newHeight <= 0 || (newWidth == currentWidth && newHeight == currentHeight) {
+				continue
+			}
+			pty.SetWindowSize(newWidth, newHeight)
+			screen.Resize(currentWidth, currentHeight, newWidth, newHeight)
+			currentWidth, currentHeight = newWidth, newHeight
+			if newWidth > largestWidth {
+				largestWidth = newWidth
+			}
+			if newHeight > largestHeight {
+				largestHeight = newHeight
+			}
+		}
+	}()
+	copied := make(chan bool)
+	go func() {
+		defer close(copied)
+		var in io.Writer = master
+		if *input {
+			in = io.MultiWriter(master, keys)
+		}
+		io.Copy(in, stdin)
+	}()
+	io.Copy(io.MultiWriter(os.Stdout, screen), master) // stops with EIO when the shell exits
+	exited := cmd.Wait()
+	signal.Stop(resized)
+	close(resized)
+	<-followed
+	width, height = largestWidth, largestHeight
+	// the next keystroke is not for the shell, and the keys are complete once the copy is over
+	stdin.SetReadDeadline(time.Now())
+	<-copied
+	stdin.Close()
+	syscall.SetNonblock(syscall.Stdin, false)
+	ttyfd.SetTermios(&orig_termios)
+	fmt.Fprintf(os.Stderr, "\nRecording finished.\n")
+
+	editorState = scriptedit.EditorState{Width: width, Height: height}
+	if err := screen.Recording(&editorState); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+	}
+	editorState.Header = scriptedit.ScriptHeader(started, os.Getenv("TERM"), width, height)
+	castHeader = scriptedit.AsciicastHeader{
+		Timestamp: started.Unix(),
+		Command:   *command,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": shell},
+	}
+	if err := writeFile(output); err != nil {
+		return err
+	}
+	if *input {
+		if err := writeInput(strings.TrimSuffix(output, scriptedit.ASCIICAST_EXTENSION), editorState.Header, keys); err != nil {
+			return err
+		}
+	}
+	if exited != nil {
+		return fmt.Errorf("the recording is saved, but %s ended with %s", cmd.Path, exited)
+	}
+	return nil
+}
+
+// interruptibleStdin returns a copy of the standard input whose reads can be stopped with a deadline.
+// The standard input shares its non-blocking mode, it is to be reset once the copy is closed.
+func interruptibleStdin() (*os.File, error) {
+	fd, err := syscall.Dup(syscall.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	syscall.SetNonblock(fd, true) // what makes os.NewFile use the poller
+	return os.NewFile(uintptr(fd), "stdin"), nil
+}
+
+// writeInput saves the keystrokes as a session and timing pair
+func writeInput(base string, header string, keys *scriptedit.Recorder) error {
+	file, err := os.Create(base + ".input")
+	if err != nil {
+		return err
+	}
+	if _, err = file.WriteString(header); err == nil {
+		_, err = file.Write(keys.Bytes())
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	file, err = os.Create(base + ".input.timing")
+	if err != nil {
+		return err
+	}
+	for _, entry := range keys.Timings() {
+		if _, err = fmt.Fprintf(file, "%f %d\n", entry.Time, entry.Length); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -2,7 +2,10 @@ package scriptedit
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"unicode/utf8"
 )
 
 const (
@@ -11,7 +14,14 @@ const (
 
 	CSI_CHR = '['
 	OSC_CHR = ']'
+	DCS_CHR = 'P'
+	APC_CHR = '_'
+	PM_CHR  = '^'
+	SOS_CHR = 'X'
+	ST_CHR  = '\\' // ESC \ is the String Terminator
 	BEL     = '\007'
+	CAN     = '\030'
+	SUB     = '\032'
 )
 
 type AnsiCode struct {
@@ -28,11 +38,91 @@ type AnsiCmd struct {
 }
 
 func (a AnsiCmd) String() string {
-	if a.Code != nil {
-		return fmt.Sprintf("%c%c%s%c", ESC_CHR, a.Code.Prefix, a.Params, a.Code.Code)
+	if a.Code == nil {
+		return string(a.Letter)
 	}
+	if *a.Code == RAW {
+		return a.Params
+	}
+	result := ESC
+	if a.Code.Prefix != 0 {
+		result += string(a.Code.Prefix)
+	}
+	result += a.Params
+	if a.Code.Code == ST_CHR && isStringIntroducer(a.Code.Prefix) {
+		result += ESC
+	}
+	if a.Code.Code != 0 {
+		result += string(a.Code.Code)
+	}
+	return result
+}
 
-	return string(a.Letter)
+// isStringIntroducer tells if ESC followed by c starts a string terminated by ST (or BEL for OSC)
+func isStringIntroducer(c rune) bool {
+	return c == OSC_CHR || c == DCS_CHR || c == APC_CHR || c == PM_CHR || c == SOS_CHR
+}
+
+// IsUnknown tells if the parser did not recognise the sequence, its bytes are kept as is in Params.
+func (a AnsiCmd) IsUnknown() bool {
+	return a.Code != nil && (*a.Code == UNKNOWN_CSI || *a.Code == UNKNOWN_ESC || *a.Code == RAW)
+}
+
+// Sequence is the generic breakdown of an escape sequence.
+type Sequence struct {
+	Introducer    rune   // CSI_CHR or the one of a string (OSC_CHR, DCS_CHR...), 0 for the other escape sequences
+	Private       string // private markers ("?", ">"...) of a CSI
+	Params        string
+	Intermediates string
+	Final         rune
+}
+
+// Sequence breaks the command down into its introducer, parameters, intermediates and final character.
+func (a AnsiCmd) Sequence() Sequence {
+	var seq Sequence
+	raw := a.String()
+	if a.Code == nil || *a.Code == RAW || len(raw) < 2 {
+		return seq
+	}
+	body := raw[1:]
+	switch body[0] {
+	case CSI_CHR:
+		seq.Introducer = CSI_CHR
+		body = body[1:]
+		i := 0
+		for i < len(body) && body[i] >= 0x3C && body[i] <= 0x3F {
+			i++
+		}
+		seq.Private, body = body[:i], body[i:]
+		i = 0
+		for i < len(body) && body[i] >= 0x30 && body[i] <= 0x3F {
+			i++
+		}
+		seq.Params, body = body[:i], body[i:]
+		i = 0
+		for i < len(body) && body[i] >= 0x20 && body[i] <= 0x2F {
+			i++
+		}
+		seq.Intermediates, body = body[:i], body[i:]
+	case OSC_CHR, DCS_CHR, APC_CHR, PM_CHR, SOS_CHR:
+		if isStringIntroducer(a.Code.Prefix) {
+			seq.Introducer = a.Code.Prefix
+			seq.Params = a.Params
+			seq.Final = a.Code.Code
+			return seq
+		}
+		fallthrough // an unterminated string
+	default:
+		i := 0
+		for i < len(body)-1 && body[i] >= 0x20 && body[i] <= 0x2F {
+			i++
+		}
+		seq.Intermediates, body = body[:i], body[i:]
+	}
+	if len(body) > 0 {
+		seq.Final = rune(body[len(body)-1])
+	}
+	return seq
 }
 
 var (
@@ -63,8 +153,28 @@ var (
 	CUPRS   = AnsiCode{CSI_CHR, 'u', "restore cursor position", "⟲"}
 	HPA     = AnsiCode{CSI_CHR, '`', "move cursor to column in current row", "↔"}
 	TBC     = AnsiCode{CSI_CHR, 'g', "clear tab stop", "↯"}
+	CHT     = AnsiCode{CSI_CHR, 'I', "move cursor to the next tab stop", "⇥"}
+	CBT     = AnsiCode{CSI_CHR, 'Z', "move cursor to the previous tab stop", "⇤"}
+	SU      = AnsiCode{CSI_CHR, 'S', "scroll up", "⇈"}
+	SD      = AnsiCode{CSI_CHR, 'T', "scroll down", "⇊"}
+	REP     = AnsiCode{CSI_CHR, 'b', "repeat the preceding character", "↻"}
+	SM      = AnsiCode{CSI_CHR, 'h', "set mode", "⚑"}
+	RM      = AnsiCode{CSI_CHR, 'l', "reset mode", "⚐"}
+	WINOPS  = AnsiCode{CSI_CHR, 't', "window manipulation", "▣"}
 
-	OSC = AnsiCode{OSC_CHR, BEL, "OSC", "☓"}
+	// sequences the parser does not know, Params holds everything after the ESC (or the CSI)
+	UNKNOWN_CSI = AnsiCode{CSI_CHR, 0, "unknown control sequence", "¿"}
+	UNKNOWN_ESC = AnsiCode{0, 0, "unknown escape sequence", "¿"}
+	// bytes that are not valid UTF-8, Params holds them as is
+	RAW = AnsiCode{0, 0, "raw bytes", "▒"}
+
+	// strings
+	OSC    = AnsiCode{OSC_CHR, BEL, "operating system command", "☓"}
+	OSC_ST = AnsiCode{OSC_CHR, ST_CHR, "operating system command", "☓"}
+	DCS    = AnsiCode{DCS_CHR, ST_CHR, "device control string", "⌘"}
+	APC    = AnsiCode{APC_CHR, ST_CHR, "application program command", "⌁"}
+	PM     = AnsiCode{PM_CHR, ST_CHR, "privacy message", "⌀"}
+	SOS    = AnsiCode{SOS_CHR, ST_CHR, "start of string", "⌂"}
 
 	// Standalone ESC codes
 	RIS   = AnsiCode{'c', 0, "Reset", "☓"}
@@ -99,10 +209,11 @@ var (
 )
 
 
-var ALL_CSI []AnsiCode = []AnsiCode { ICH, CUU, CUD, CUF, CUB, CNL, CPL, CHA, CUP, ED , EL , IL , DL , DCH, ECH, HPR, DA , VPA, VPR, HVP, SGR, DSR, DECSTBM, CUPSV, CUPRS, HPA, TBC}
+var ALL_CSI []AnsiCode = []AnsiCode { ICH, CUU, CUD, CUF, CUB, CNL, CPL, CHA, CUP, ED , EL , IL , DL , DCH, ECH, HPR, DA , VPA, VPR, HVP, SGR, DSR, DECSTBM, CUPSV, CUPRS, HPA, TBC, CHT, CBT, SU, SD, REP, SM, RM, WINOPS}
 var ALL_G0 []AnsiCode = []AnsiCode { G0MAP_8859, G0MAP_VT100, G0MAP_NULL, G0MAP_USER}
 var ALL_G1 []AnsiCode = []AnsiCode { G1MAP_8859, G1MAP_VT100, G1MAP_NULL, G1MAP_USER}
 var ALL_ENCODING []AnsiCode = []AnsiCode { ISO8859, UTF8, UTF8_OLD}
+var ALL_STRINGS []AnsiCode = []AnsiCode {OSC, OSC_ST, DCS, APC, PM, SOS}
 var ALL_SINGLES []AnsiCode = []AnsiCode {RIS, IND, NEL, HTS, RI, DECID, DECSC, DECRC, DECPNM, DECPAM}
 
 
@@ -118,68 +229,214 @@ const SMCUP = ESC + "7" + ESC + "[?47h"
 const RMCUP = ESC + "[2J" + ESC + "[?47l" + ESC + "8"
 
 
-func ParseANSI(reader *bufio.Reader) []AnsiCmd {
-	var result []AnsiCmd = make([]AnsiCmd, 0)
+func findCode(codes []AnsiCode, prefix, code rune) *AnsiCode {
+	for i := range codes {
+		if codes[i].Prefix == prefix && codes[i].Code == code {
+			return &codes[i]
+		}
+	}
+	return nil
+}
+
+// SyntaxError reports a malformed or truncated escape sequence in the stream.
+type SyntaxError struct {
+	Offset int64 // byte offset of the ESC starting the sequence
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Msg)
+}
+
+// Tokenizer cuts a stream into characters and escape sequences, one AnsiCmd at a time.
+type Tokenizer struct {
+	reader *bufio.Reader
+	offset int64
+}
+
+func NewTokenizer(reader io.Reader) *Tokenizer {
+	return &Tokenizer{reader: bufio.NewReader(reader)}
+}
+
+// Offset returns the number of bytes consumed so far.
+func (t *Tokenizer) Offset() int64 {
+	return t.offset
+}
+
+func (t *Tokenizer) readByte() (byte, error) {
+	b, err := t.reader.ReadByte()
+	if err == nil {
+		t.offset++
+	}
+	return b, err
+}
+
+func (t *Tokenizer) unreadByte() {
+	t.reader.UnreadByte()
+	t.offset--
+}
+
+// Next returns the next command of the stream, io.EOF at the end of it.
+// Nothing is lost: a malformed or truncated sequence is still returned with its bytes,
+// as an unknown sequence, along with a *SyntaxError.
+func (t *Tokenizer) Next() (AnsiCmd, error) {
+	start := t.offset
+	b, size, err := t.reader.ReadRune()
+	if err != nil {
+		return AnsiCmd{}, err
+	}
+	t.offset += int64(size)
+	if b == utf8.RuneError && size == 1 { // not UTF-8, keep the byte as is
+		t.reader.UnreadRune()
+		raw, _ := t.reader.ReadByte()
+		return AnsiCmd{0, &RAW, string([]byte{raw})}, nil
+	}
+	if b != ESC_CHR {
+		return AnsiCmd{b, nil, ""}, nil
+	}
+	next, err := t.readByte()
+	if err == io.EOF {
+		return AnsiCmd{ESC_CHR, nil, ""}, &SyntaxError{start, "truncated escape sequence"}
+	}
+	if err != nil {
+		return AnsiCmd{}, err
+	}
+	if next < 0x20 || next > 0x7E {
+		// a lone ESC, what follows is not part of a sequence
+		t.unreadByte()
+		return AnsiCmd{ESC_CHR, nil, ""}, &SyntaxError{start, fmt.Sprintf("unexpected %q after ESC", next)}
+	}
+	switch next {
+	case CSI_CHR:
+		return t.controlSequence(start)
+	case OSC_CHR, DCS_CHR, APC_CHR, PM_CHR, SOS_CHR:
+		return t.stringSequence(start, next)
+	}
+	return t.escapeSequence(start, next)
+}
+
+// controlSequence reads what follows "ESC [": parameters, intermediates and a final byte
+func (t *Tokenizer) controlSequence(start int64) (AnsiCmd, error) {
+	var params bytes.Buffer
 	for {
-		b, _, err := reader.ReadRune()
+		b, err := t.readByte()
+		if err == io.EOF {
+			return AnsiCmd{0, &UNKNOWN_CSI, params.String()}, &SyntaxError{start, "truncated control sequence"}
+		}
+		if err != nil {
+			return AnsiCmd{}, err
+		}
+		if b >= 0x40 && b <= 0x7E {
+			if code := findCode(ALL_CSI, CSI_CHR, rune(b)); code != nil {
+				return AnsiCmd{0, code, params.String()}, nil
+			}
+			params.WriteByte(b)
+			return AnsiCmd{0, &UNKNOWN_CSI, params.String()}, nil
+		}
+		if b < 0x20 || b > 0x3F {
+			t.unreadByte()
+			return AnsiCmd{0, &UNKNOWN_CSI, params.String()}, &SyntaxError{start, fmt.Sprintf("unexpected %q in control sequence", b)}
+		}
+		params.WriteByte(b)
+	}
+}
+
+// stringSequence reads a string up to its terminator: ST (ESC \\) or BEL for an OSC
+func (t *Tokenizer) stringSequence(start int64, introducer byte) (AnsiCmd, error) {
+	var params bytes.Buffer
+	for {
+		if next, _ := t.reader.Peek(2); len(next) == 2 && next[0] == ESC_CHR {
+			if next[1] == ST_CHR {
+				t.readByte()
+				t.readByte()
+				return AnsiCmd{0, findCode(ALL_STRINGS, rune(introducer), ST_CHR), params.String()}, nil
+			}
+			if next[1] == ESC_CHR && introducer == DCS_CHR { // an escaped ESC, like in the tmux passthrough
+				t.readByte()
+				t.readByte()
+				params.WriteString(ESC + ESC)
+				continue
+			}
+		}
+		b, err := t.readByte()
+		if err == io.EOF {
+			return AnsiCmd{0, &UNKNOWN_ESC, string(introducer) + params.String()}, &SyntaxError{start, "truncated string sequence"}
+		}
 		if err != nil {
-			break
+			return AnsiCmd{}, err
+		}
+		if b == BEL && introducer == OSC_CHR {
+			return AnsiCmd{0, findCode(ALL_STRINGS, OSC_CHR, BEL), params.String()}, nil
 		}
-		if b == ESC_CHR {
-			b, _, err = reader.ReadRune()
-			switch b {
-			case CSI_CHR:
-				params := ""
-				b, _, err = reader.ReadRune()
-				for b < 0x40 || b > 0x7E {
-					params = fmt.Sprintf("%s%c", params, b)
-					b, _, err = reader.ReadRune()
-				}
-				for _, code := range ALL_CSI {
-					if code.Code == b {
-						result = append(result, AnsiCmd{0, &code, string(params)})
-						break
-					}
-				}
-			case OSC_CHR:
-				params := ""
-				b, _, err = reader.ReadRune()
-				for b != BEL {
-					params = fmt.Sprintf("%s%c", params, b)
-					b, _, err = reader.ReadRune()
-				}
-				result = append(result, AnsiCmd{0, &OSC, string(params)})
-			case '(':
-				b, _, err = reader.ReadRune()
-				for _, code := range ALL_G0 {
-					if code.Code == b {
-						result = append(result, AnsiCmd{0, &code, ""})
-						break
-					}
-				}
-			case ')':
-				b, _, err = reader.ReadRune()
-				for _, code := range ALL_G1 {
-					if code.Code == b {
-						result = append(result, AnsiCmd{0, &code, ""})
-						break
-					}
-				}
-			default:
-				for _, code := range ALL_SINGLES {
-					if code.Code == b {
-						result = append(result, AnsiCmd{0, &code, ""})
-						break
-					}
-				}
-				fmt.Printf("PARSING ERROR on Single %c", rune(b))
+		if b == ESC_CHR || b == CAN || b == SUB {
+			t.unreadByte()
+			return AnsiCmd{0, &UNKNOWN_ESC, string(introducer) + params.String()}, &SyntaxError{start, "unterminated string sequence"}
+		}
+		params.WriteByte(b)
+	}
+}
 
+// escapeSequence reads "ESC", intermediate bytes (0x20-0x2F) then a final byte
+func (t *Tokenizer) escapeSequence(start int64, first byte) (AnsiCmd, error) {
+	var params bytes.Buffer
+	params.WriteByte(first)
+	for b := first; b >= 0x20 && b <= 0x2F; {
+		var err error
+		b, err = t.readByte()
+		if err == io.EOF {
+			return AnsiCmd{0, &UNKNOWN_ESC, params.String()}, &SyntaxError{start, "truncated escape sequence"}
+		}
+		if err != nil {
+			return AnsiCmd{}, err
+		}
+		if b < 0x20 || b > 0x7E {
+			t.unreadByte()
+			return AnsiCmd{0, &UNKNOWN_ESC, params.String()}, &SyntaxError{start, fmt.Sprintf("unexpected %q in escape sequence", b)}
+		}
+		params.WriteByte(b)
+	}
+	sequence := params.Bytes()
+	var code *AnsiCode
+	switch len(sequence) {
+	case 1:
+		code = findCode(ALL_SINGLES, rune(sequence[0]), 0)
+	case 2:
+		code = findCode(ALL_G0, rune(sequence[0]), rune(sequence[1]))
+		if code == nil {
+			code = findCode(ALL_G1, rune(sequence[0]), rune(sequence[1]))
+		}
+		if code == nil {
+			code = findCode(ALL_ENCODING, rune(sequence[0]), rune(sequence[1]))
+		}
+	}
+	if code != nil {
+		return AnsiCmd{0, code, ""}, nil
+	}
+	return AnsiCmd{0, &UNKNOWN_ESC, params.String()}, nil
+}
+
+// ParseANSI cuts the whole stream into characters and escape sequences.
+// Rendering the result back with String() gives the original bytes.
+// Malformed sequences do not stop the parsing, the first one is reported as a *SyntaxError
+// once the stream is entirely parsed; any other error is returned right away.
+func ParseANSI(reader io.Reader) ([]AnsiCmd, error) {
+	var result []AnsiCmd = make([]AnsiCmd, 0)
+	var syntaxError error
+	tokenizer := NewTokenizer(reader)
+	for {
+		cmd, err := tokenizer.Next()
+		if err == io.EOF {
+			return result, syntaxError
+		}
+		if _, ok := err.(*SyntaxError); ok {
+			if syntaxError == nil {
+				syntaxError = err
 			}
-		} else {
-			result = append(result, AnsiCmd{b, nil, ""})
+		} else if err != nil {
+			return result, err
 		}
+		result = append(result, cmd)
 	}
-	return result
 }
 
 func EdulcorateCharacter(c rune) rune {
@@ -251,7 +508,6 @@ func EdulcorateCharacter(c rune) rune {
 	default:
 		return c
 	}
-	return '?'
 }
 
 func RuneWidth(r rune) int {
@@ -4,11 +4,20 @@ import (
 	"testing"
 	"strings"
 	"bufio"
+	"io"
 )
 
+func parse(doc string) []AnsiCmd {
+	cmds, _ := ParseANSI(strings.NewReader(doc))
+	return cmds
+}
+
 func TestParsing(t *testing.T) {
 	r := bufio.NewReader(strings.NewReader("This is a normal string\033[12;13fNormal Again\033[A"))
-	parsedAnsi := ParseANSI(r)
+	parsedAnsi, err := ParseANSI(r)
+	if err != nil {
+		t.Error(err)
+	}
 	if len(parsedAnsi) != 37 {
 		t.Errorf("Wrong length %d ", len(parsedAnsi))
 	}
@@ -38,7 +47,7 @@ func TestUnitRendering(t *testing.T) {
 func TestRendering(t *testing.T) {
 	orig := "This is a normal string\033[12;13fNormal Again\033[A"
 	r := bufio.NewReader(strings.NewReader(orig))
-	parsedAnsi := ParseANSI(r)
+	parsedAnsi, _ := ParseANSI(r)
 	var dest string = ""
 	for _, ansi := range parsedAnsi {
 		dest += ansi.String()
@@ -71,3 +80,151 @@ func TestRuneWidth(t *testing.T) {
 }
 
 
+
+var losslessTests = []string{
+	"\033[?25l\033[?1049h\033[8;43;132t\033[2 q",
+	"\0337\0338\033#8\033(A\033(B\033%G\033 F",
+	"invalid \xff\xfe utf-8 \xe2\x82",
+	"\033]0;title\007\033c\033\r\033",
+}
+
+func TestLossless(t *testing.T) {
+	for _, orig := range losslessTests {
+		parsedAnsi := parse(orig)
+		var dest string
+		for _, ansi := range parsedAnsi {
+			dest += ansi.String()
+		}
+		if orig != dest {
+			t.Errorf("Not lossless %q != %q", orig, dest)
+		}
+	}
+}
+
+func TestUnknownSequence(t *testing.T) {
+	parsedAnsi := parse("\033[?25l\033[2 q\033[1;2y\033#8\0337")
+	if len(parsedAnsi) != 5 {
+		t.Fatalf("Wrong length %d", len(parsedAnsi))
+	}
+	if parsedAnsi[0].Code.Symbol != RM.Symbol || parsedAnsi[0].IsUnknown() {
+		t.Error("Could not parse a DEC private mode")
+	}
+	seq := parsedAnsi[1].Sequence()
+	if seq.Introducer != CSI_CHR || seq.Params != "2" || seq.Intermediates != " " || seq.Final != 'q' || !parsedAnsi[1].IsUnknown() {
+		t.Errorf("Wrong breakdown %+v", seq)
+	}
+	seq = parsedAnsi[2].Sequence()
+	if seq.Params != "1;2" || seq.Final != 'y' {
+		t.Errorf("Wrong breakdown %+v", seq)
+	}
+	seq = parsedAnsi[0].Sequence()
+	if seq.Private != "?" || seq.Params != "25" || seq.Final != 'l' {
+		t.Errorf("Wrong breakdown %+v", seq)
+	}
+	seq = parsedAnsi[3].Sequence()
+	if seq.Introducer != 0 || seq.Intermediates != "#" || seq.Final != '8' {
+		t.Errorf("Wrong breakdown %+v", seq)
+	}
+	if *parsedAnsi[4].Code != DECSC {
+		t.Error("Could not parse a single ESC code")
+	}
+}
+
+type MalformedTest struct {
+	in     string
+	offset int64
+}
+
+var malformedtests = []MalformedTest{
+	{"abc\033[12", 3},
+	{"abc\033[12\033[Adef", 3},
+	{"\033]0;title", 0},
+	{"ab\033]0;title\033[A", 2},
+	{"abcd\033", 4},
+	{"a\033\r", 1},
+	{"a\033#", 1},
+}
+
+func TestMalformed(t *testing.T) {
+	for _, tt := range malformedtests {
+		parsedAnsi, err := ParseANSI(strings.NewReader(tt.in))
+		syntaxError, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: no syntax error reported (%v)", tt.in, err)
+			continue
+		}
+		if syntaxError.Offset != tt.offset {
+			t.Errorf("%q: wrong offset %d", tt.in, syntaxError.Offset)
+		}
+		var dest string
+		for _, ansi := range parsedAnsi {
+			dest += ansi.String()
+		}
+		if dest != tt.in {
+			t.Errorf("Not lossless %q != %q", tt.in, dest)
+		}
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	tokenizer := NewTokenizer(strings.NewReader("a\033[1mé"))
+	expected := []int64{1, 5, 7}
+	for _, offset := range expected {
+		if _, err := tokenizer.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if tokenizer.Offset() != offset {
+			t.Errorf("Wrong offset %d != %d", tokenizer.Offset(), offset)
+		}
+	}
+	if _, err := tokenizer.Next(); err != io.EOF {
+		t.Errorf("No EOF at the end %v", err)
+	}
+}
+
+type StringTest struct {
+	in          string
+	code        AnsiCode
+	params      string
+	explanation string
+}
+
+var stringtests = []StringTest{
+	{"\033]0;my title\007", OSC, "0;my title", "set icon name and window title: my title"},
+	{"\033]2;my title\033\\", OSC_ST, "2;my title", "set window title: my title"},
+	{"\033]8;;http://example.com\033\\", OSC_ST, "8;;http://example.com", "hyperlink to http://example.com"},
+	{"\033]133;A\007", OSC, "133;A", "shell integration: prompt starts"},
+	{"\033P$qm\033\\", DCS, "$qm", "request setting (m)"},
+	{"\033Ptmux;\033\033]0;x\007\033\\", DCS, "tmux;\033\033]0;x\007", "tmux passthrough (7 bytes)"},
+	{"\033P0;0;0q#0;2;0;0;0\033\\", DCS, "0;0;0q#0;2;0;0;0", "sixel graphics (16 bytes)"},
+	{"\033_Gf=100;AAAA\033\\", APC, "Gf=100;AAAA", "kitty graphics (11 bytes)"},
+	{"\033^secret\033\\", PM, "secret", "privacy message (6 bytes)"},
+	{"\033Xstring\033\\", SOS, "string", "start of string (6 bytes)"},
+}
+
+func TestStrings(t *testing.T) {
+	for _, tt := range stringtests {
+		parsedAnsi, err := ParseANSI(strings.NewReader(tt.in + "a"))
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if len(parsedAnsi) != 2 || parsedAnsi[1].Letter != 'a' {
+			t.Errorf("%q: wrong length %d", tt.in, len(parsedAnsi))
+			continue
+		}
+		cmd := parsedAnsi[0]
+		if *cmd.Code != tt.code || cmd.Params != tt.params {
+			t.Errorf("%q: wrong parsing %+v %q", tt.in, *cmd.Code, cmd.Params)
+		}
+		if cmd.String() != tt.in {
+			t.Errorf("Not lossless %q != %q", tt.in, cmd.String())
+		}
+		if cmd.Explain() != tt.explanation {
+			t.Errorf("%q: wrong explanation %q", tt.in, cmd.Explain())
+		}
+		if seq := cmd.Sequence(); seq.Introducer != tt.code.Prefix || seq.Final != tt.code.Code {
+			t.Errorf("%q: wrong breakdown %+v", tt.in, seq)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package scriptedit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+const ASCIICAST_EXTENSION = ".cast"
+
+// AsciicastHeader is the first line of an asciicast v2 file, see
+// https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+type AsciicastHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// ParseAsciicast loads an asciicast v2 recording: the output events become the Content,
+// their absolute timestamps the relative delays of the Timings.
+// Like ParseANSI, a *SyntaxError means the output has malformed sequences but is loaded anyway.
+func (state *EditorState) ParseAsciicast(reader io.Reader) (*AsciicastHeader, error) {
+	lines := bufio.NewReader(reader)
+	line, err := lines.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("asciicast: no header: %s", err)
+	}
+	header := new(AsciicastHeader)
+	if err := json.Unmarshal(line, header); err != nil {
+		return nil, fmt.Errorf("asciicast: invalid header: %s", err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("asciicast: unsupported version %d", header.Version)
+	}
+
+	var output bytes.Buffer
+	var last float64
+	timings := make([]Timing, 0)
+	var markers []Marker // positioned by byte offset until the output is parsed
+	for number := 2; ; number++ {
+		line, err := lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var event []interface{}
+			if err := json.Unmarshal(line, &event); err != nil {
+				return nil, fmt.Errorf("asciicast: line %d: %s", number, err)
+			}
+			if len(event) != 3 {
+				return nil, fmt.Errorf("asciicast: line %d: invalid event", number)
+			}
+			at, atOk := event[0].(float64)
+			code, codeOk := event[1].(string)
+			data, dataOk := event[2].(string)
+			if !atOk || !codeOk || !dataOk {
+				return nil, fmt.Errorf("asciicast: line %d: invalid event", number)
+			}
+			// input events are not part of the output, a resize is the sequence resizing the terminal
+			switch {
+			case code == "r":
+				var width, height int
+				if _, err := fmt.Sscanf(data, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+					return nil, fmt.Errorf("asciicast: line %d: invalid resize %q", number, data)
+				}
+				data = fmt.Sprintf(CHANGE_SIZE, height, width)
+				fallthrough
+			case code == "o" && data != "":
+				timings = append(timings, Timing{float32(at - last), len(data)})
+				output.WriteString(data)
+				last = at
+			case code == "m":
+				markers = append(markers, Marker{output.Len(), data})
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := ParseANSI(&output)
+	if _, malformed := err.(*SyntaxError); err != nil && !malformed {
+		return nil, err
+	}
+	state.Content = content
+	state.Timings = timings
+	state.Width, state.Height = header.Width, header.Height
+	state.undoStack, state.redoStack = nil, nil
+	state.Markers = nil
+	for _, marker := range markers {
+		position := state.Bytepos2position(marker.Position)
+		if position < 0 {
+			position = len(state.Content)
+		}
+		state.AddMarker(position, marker.Label)
+	}
+	state.changed(0)
+	return header, err
+}
+
+// ScriptHeader is the first line script would have written for this recording,
+// so it can be saved as a session and timing pair.
+func (header *AsciicastHeader) ScriptHeader() string {
+	started := time.Unix(header.Timestamp, 0)
+	if header.Timestamp == 0 {
+		started = time.Now()
+	}
+	return ScriptHeader(started, header.Env["TERM"], header.Width, header.Height)
+}
+
+// WriteAsciicast writes the recording as an asciicast v2 file. The size and duration
+// of the header are filled from the state.
+func (state *EditorState) WriteAsciicast(writer io.Writer, header AsciicastHeader) error {
+	header.Version = 2
+	header.Width, header.Height = state.TerminalSize()
+	header.Duration = 0
+	for _, timing := range state.Timings {
+		header.Duration += float64(timing.Time)
+	}
+	header.Duration = math.Round(header.Duration*1e6) / 1e6
+	line, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(writer)
+	output.Write(line)
+	output.WriteByte('\n')
+
+	var content bytes.Buffer
+	for _, ansi := range state.Content {
+		content.WriteString(ansi.String())
+	}
+	raw := content.Bytes()
+	var at float64
+	var pending []byte
+	markers := state.Markers
+	bytepos := 0
+	for _, timing := range state.Timings {
+		at += float64(timing.Time)
+		// the chapters starting in this chunk are marked just before it
+		for len(markers) > 0 && state.Position2Bytepos(markers[0].Position) < bytepos+timing.Length {
+			writeMarker(output, at, markers[0].Label)
+			markers = markers[1:]
+		}
+		bytepos += timing.Length
+		length := timing.Length
+		if length > len(raw) {
+			length = len(raw)
+		}
+		pending = append(pending, raw[:length]...)
+		raw = raw[length:]
+		// a character split between two chunks is written with the second one
+		data := pending[:validPrefix(pending)]
+		if len(data) > 0 {
+			writeEvent(output, at, data)
+			pending = pending[len(data):]
+		}
+	}
+	for _, marker := range markers {
+		writeMarker(output, at, marker.Label)
+	}
+	if pending = append(pending, raw...); len(pending) > 0 {
+		writeEvent(output, at, pending)
+	}
+	return output.Flush()
+}
+
+// writeEvent writes data as an output event, or as a resize event when it only resizes the terminal.
+func writeEvent(output *bufio.Writer, at float64, data []byte) {
+	var width, height int
+	if _, err := fmt.Sscanf(string(data), CHANGE_SIZE, &height, &width); err == nil && fmt.Sprintf(CHANGE_SIZE, height, width) == string(data) {
+		fmt.Fprintf(output, "[%.6f, \"r\", \"%dx%d\"]\n", at, width, height)
+		return
+	}
+	encoded, _ := json.Marshal(string(data))
+	fmt.Fprintf(output, "[%.6f, \"o\", %s]\n", at, encoded)
+}
+
+func writeMarker(output *bufio.Writer, at float64, label string) {
+	encoded, _ := json.Marshal(label)
+	fmt.Fprintf(output, "[%.6f, \"m\", %s]\n", at, encoded)
+}
+
+// validPrefix returns the length of data without a truncated UTF-8 character at its end.
+func validPrefix(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
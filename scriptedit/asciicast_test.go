@@ -0,0 +1,128 @@
+package scriptedit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const CAST = `{"version": 2, "width": 80, "height": 24, "timestamp": 1504467315, "title": "Demo"}
+[0.5, "o", "hello "]
+[0.75, "i", "x"]
+[1.25, "o", "\u001b[1mworld\u001b[0m"]
+
+[2.0, "m", "chapter"]
+[3.0, "o", "\r\n"]
+`
+
+func TestParseAsciicast(t *testing.T) {
+	state := NewEditorState()
+	header, err := state.ParseAsciicast(strings.NewReader(CAST))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if header.Title != "Demo" || header.Width != 80 || header.Height != 24 {
+		t.Errorf("Wrong header %+v", header)
+	}
+	expected := []Timing{Timing{0.5, 6}, Timing{0.75, 13}, Timing{1.75, 2}}
+	if !reflect.DeepEqual(state.Timings, expected) {
+		t.Errorf("Wrong timings %v", state.Timings)
+	}
+	if state.Total_time != 3 {
+		t.Errorf("Wrong total time %f", state.Total_time)
+	}
+	var content string
+	for _, ansi := range state.Content {
+		content += ansi.String()
+	}
+	if content != "hello \033[1mworld\033[0m\r\n" {
+		t.Errorf("Wrong content %q", content)
+	}
+	// the chapter starts with the next output
+	if !reflect.DeepEqual(state.Markers, []Marker{Marker{len(state.Content) - 2, "chapter"}}) {
+		t.Errorf("Wrong markers %v", state.Markers)
+	}
+	if state.MarkerTime(state.Markers[0]) != 3 {
+		t.Errorf("Wrong marker time %f", state.MarkerTime(state.Markers[0]))
+	}
+	if width, height := state.TerminalSize(); width != 80 || height != 24 {
+		t.Errorf("Wrong terminal size %dx%d", width, height)
+	}
+	if !strings.Contains(header.ScriptHeader(), `COLUMNS="80" LINES="24"`) {
+		t.Errorf("Wrong script header %q", header.ScriptHeader())
+	}
+}
+
+func TestParseAsciicastInvalid(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		`{"version": 1, "width": 80, "height": 24}`,
+		"{\"version\": 2, \"width\": 80, \"height\": 24}\n[0.5, \"o\"]\n",
+		"{\"version\": 2, \"width\": 80, \"height\": 24}\n[\"0.5\", \"o\", \"a\"]\n",
+		"{\"version\": 2, \"width\": 80, \"height\": 24}\n[0.5, \"r\", \"wide\"]\n",
+	} {
+		if _, err := NewEditorState().ParseAsciicast(strings.NewReader(doc)); err == nil {
+			t.Errorf("%q should have been rejected", doc)
+		}
+	}
+}
+
+func TestParseAsciicastResize(t *testing.T) {
+	state := NewEditorState()
+	_, err := state.ParseAsciicast(strings.NewReader("{\"version\": 2, \"width\": 80, \"height\": 24}\n[0.5, \"o\", \"a\"]\n[1, \"r\", \"100x30\"]\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if len(state.Content) != 2 || state.Content[1].String() != "\033[8;30;100t" || state.Timings[1] != (Timing{0.5, 11}) {
+		t.Errorf("The resize should be kept in the recording %v %v", state.Content, state.Timings)
+	}
+	if screen := state.ScreenAt(2); screen.Width != 100 || screen.Height != 30 {
+		t.Errorf("Wrong size %dx%d", screen.Width, screen.Height)
+	}
+	var output strings.Builder
+	if err = state.WriteAsciicast(&output, AsciicastHeader{}); err != nil || !strings.HasSuffix(output.String(), "[1.000000, \"r\", \"100x30\"]\n") {
+		t.Errorf("The resize should be exported as a resize event %q %v", output.String(), err)
+	}
+}
+
+func TestWriteAsciicast(t *testing.T) {
+	state := NewEditorState()
+	if _, err := state.ParseAsciicast(strings.NewReader(CAST)); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	var output strings.Builder
+	if err := state.WriteAsciicast(&output, AsciicastHeader{Title: "Demo"}); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	expected := `{"version":2,"width":80,"height":24,"duration":3,"title":"Demo"}
+[0.500000, "o", "hello "]
+[1.250000, "o", "\u001b[1mworld\u001b[0m"]
+[3.000000, "m", "chapter"]
+[3.000000, "o", "\r\n"]
+`
+	if output.String() != expected {
+		t.Errorf("Wrong export\n%s", output.String())
+	}
+
+	reloaded := NewEditorState()
+	if _, err := reloaded.ParseAsciicast(strings.NewReader(output.String())); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(reloaded.Content, state.Content) || !reflect.DeepEqual(reloaded.Timings, state.Timings) ||
+		!reflect.DeepEqual(reloaded.Markers, state.Markers) {
+		t.Errorf("The export does not round-trip: %v", reloaded.Timings)
+	}
+}
+
+func TestWriteAsciicastSplitCharacter(t *testing.T) {
+	state := NewEditorState()
+	state.Content, _ = ParseANSI(strings.NewReader("a┬b"))
+	state.Timings = []Timing{Timing{1, 2}, Timing{1, 3}}
+	state.changed(0)
+	var output strings.Builder
+	state.WriteAsciicast(&output, AsciicastHeader{})
+	lines := strings.Split(output.String(), "\n")
+	if lines[1] != `[1.000000, "o", "a"]` || lines[2] != `[2.000000, "o", "┬b"]` {
+		t.Errorf("Wrong events %q", lines[1:])
+	}
+}
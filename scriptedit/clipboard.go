@@ -0,0 +1,75 @@
+package scriptedit
+
+// Clip is a part of a recording, with the timings to replay it.
+type Clip struct {
+	Content []AnsiCmd
+	Timings []Timing
+}
+
+// Copy puts Content[from:to] and its timings in the Clipboard, the chunks crossing the
+// boundaries of the region are split.
+func (state *EditorState) Copy(from, to int) *Clip {
+	if to > len(state.Content) {
+		to = len(state.Content)
+	}
+	if from < 0 || from >= to {
+		return nil
+	}
+	scratch := EditorState{Content: state.Content, Timings: append([]Timing(nil), state.Timings...)}
+	first := scratch.splitTiming(state.Position2Bytepos(from))
+	last := scratch.splitTiming(state.Position2Bytepos(to))
+	state.Clipboard = &Clip{
+		Content: append([]AnsiCmd(nil), state.Content[from:to]...),
+		Timings: append([]Timing(nil), scratch.Timings[first:last]...),
+	}
+	return state.Clipboard
+}
+
+// Cut copies Content[from:to] in the Clipboard and removes it from the recording.
+func (state *EditorState) Cut(from, to int) *Clip {
+	clip := state.Copy(from, to)
+	if clip == nil {
+		return nil
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	first := state.splitTiming(state.Position2Bytepos(from))
+	last := state.splitTiming(state.Position2Bytepos(to))
+	state.Timings = spliceTimings(state.Timings, first, last-first, nil)
+	state.joinTiming(first)
+	state.Content = spliceContent(state.Content, from, to-from, nil)
+	if state.Position > to {
+		state.Position -= to - from
+	} else if state.Position > from {
+		state.Position = from
+	}
+	state.record(from, clip.Content, nil, oldTimings, Operation{Name: OP_CUT, From: from, To: to})
+	return clip
+}
+
+// Paste inserts clip at position with its timings, it can come from another recording.
+func (state *EditorState) Paste(position int, clip *Clip) bool {
+	bytepos := state.Position2Bytepos(position)
+	if clip == nil || len(clip.Content) == 0 || bytepos < 0 {
+		return false
+	}
+	op := Operation{Name: OP_PASTE, From: position, To: position, Timings: clip.Timings}
+	for _, ansi := range clip.Content {
+		op.Text = append(op.Text, ansi.String()...)
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	index := state.splitTiming(bytepos)
+	state.Timings = spliceTimings(state.Timings, index, 0, clip.Timings)
+	state.joinTiming(index + len(clip.Timings))
+	state.joinTiming(index)
+	state.Content = spliceContent(state.Content, position, 0, clip.Content)
+	state.record(position, nil, clip.Content, oldTimings, op)
+	return true
+}
+
+// joinTiming merges the timing at index in the previous one when it has no delay, which replays the same.
+func (state *EditorState) joinTiming(index int) {
+	if index > 0 && index < len(state.Timings) && state.Timings[index].Time == 0 {
+		state.Timings[index-1].Length += state.Timings[index].Length
+		state.Timings = spliceTimings(state.Timings, index, 1, nil)
+	}
+}
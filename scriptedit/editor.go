@@ -3,6 +3,7 @@ package scriptedit
 import (
 	"bufio"
 	"fmt"
+	"io"
 )
 
 type Timing struct {
@@ -19,6 +20,19 @@ type EditorState struct {
 	Total_time     float32   // The total time of the replay
 	In		     int       // The IN marker
 	Out            int       // The OUT marker
+	Header         string    // The first line of the session written by script
+	Width          int       // The size of the recorded terminal, 0 if unknown
+	Height         int
+	Markers        []Marker  // The chapters, sorted by position
+	Clipboard      *Clip     // The last region copied or cut, see clipboard.go
+	Dirty          bool      // Set by the edits, to be cleared once they are saved
+	Journal        io.Writer // When set, every edit is written to it, see project.go
+
+	screen         *Screen   // headless screen cached at screenPosition
+	screenPosition int
+	keyframes      []keyframe // seek index, see seek.go
+	undoStack      []edit     // edit history, see history.go
+	redoStack      []edit
 }
 
 func NewEditorState() *EditorState {
@@ -28,6 +42,18 @@ func NewEditorState() *EditorState {
 	return state
 }
 
+// TerminalSize returns the size of the recorded terminal, WIDTH x HEIGHT if unknown.
+func (state *EditorState) TerminalSize() (int, int) {
+	width, height := state.Width, state.Height
+	if width <= 0 {
+		width = WIDTH
+	}
+	if height <= 0 {
+		height = HEIGHT
+	}
+	return width, height
+}
+
 func (state *EditorState) Position2Bytepos(position int) int {
 	var offset int
 	for index, ansi := range state.Content {
@@ -36,6 +62,9 @@ func (state *EditorState) Position2Bytepos(position int) int {
 		}
 		offset+= len(ansi.String())
 	}
+	if position == len(state.Content) {
+		return offset
+	}
 	return -1
 }
 
@@ -53,6 +82,42 @@ func (state *EditorState) Bytepos2position(bytepos int) int {
 	return -1
 }
 
+// TimeToPosition returns the position of the first command played at or after time.
+func (state *EditorState) TimeToPosition(time float32) int {
+	var now float32
+	var bytepos int
+	for _, t := range state.Timings {
+		now += t.Time
+		if now >= time {
+			break
+		}
+		bytepos += t.Length
+	}
+	if position := state.Bytepos2position(bytepos); position >= 0 {
+		return position
+	}
+	return len(state.Content)
+}
+
+// Validate lists the inconsistencies between the Content and the Timings.
+func (state *EditorState) Validate() []string {
+	var problems []string
+	var bytes, played int
+	for _, ansi := range state.Content {
+		bytes += len(ansi.String())
+	}
+	for index, t := range state.Timings {
+		if t.Time < 0 || t.Length < 0 {
+			problems = append(problems, fmt.Sprintf("chunk %d has a negative delay or length (%f %d)", index, t.Time, t.Length))
+		}
+		played += t.Length
+	}
+	if played != bytes {
+		problems = append(problems, fmt.Sprintf("the timings play %d bytes but the session has %d", played, bytes))
+	}
+	return problems
+}
+
 // it gets the correct timing for a given absolute byte offset in the stream
 func (state *EditorState) deduceTiming(offset int) (int, int, float32) {
 	var time float32
@@ -117,42 +182,48 @@ func (state *EditorState) Previous() bool {
 
 
 
-func (state *EditorState) DeleteRegion(from_position, to_position int) bool {
-	var bytesToRemove int
-	for i := from_position; i < to_position; i++ {
-		bytesToRemove += len([]byte(state.Content[i].String()))
-	}
-	from_offset := state.Position2Bytepos(from_position)
-
-	timeindex, timingBaseOffset, _ := state.deduceTiming(from_offset) //  find back from with time bucket it is from
-	nextTimingBaseOffset := timingBaseOffset + state.Timings[timeindex].Length
-
-	if to_position >= nextTimingBaseOffset && from_offset != timingBaseOffset { // ho, we need to cut in the middle the first element
-		to_remove := nextTimingBaseOffset - from_offset
-		state.Timings[timeindex].Length -= to_remove
-		bytesToRemove -=  to_remove
-		timeindex++
-	}
-
-	for {
-		currentElementLength := state.Timings[timeindex].Length
-		if currentElementLength > bytesToRemove {
-			state.Timings[timeindex].Length-= bytesToRemove
-			break
+// NextSameCursorPosition moves the Position forward to where the cursor is back at the same place.
+// It returns false if it never happens.
+func (state *EditorState) NextSameCursorPosition() bool {
+	screen := state.ScreenAt(state.Position).Clone()
+	initx, inity := screen.X, screen.Y
+	for position := state.Position; position < len(state.Content); {
+		screen.Apply(state.Content[position])
+		position++
+		if screen.X == initx && screen.Y == inity {
+			state.Position = position
+			state.Bytepos = state.Position2Bytepos(position)
+			_, _, state.Time = state.deduceTiming(state.Bytepos)
+			return true
 		}
-		bytesToRemove -= currentElementLength
-		copy(state.Timings[timeindex:], state.Timings[timeindex + 1:])
-		state.Timings = state.Timings[:len(state.Timings) - 1]
 	}
-
-	copy(state.Content[from_position:], state.Content[to_position:])
-	state.Content = state.Content[:len(state.Content) - (to_position - from_position)]
-	_, _, state.Time = state.deduceTiming(state.Bytepos)
-
 	return false
-
 }
 
+// DeleteRegion removes Content[from_position:to_position] and its part of the Timings,
+// the delays of what is left stay. It returns false when there is nothing to remove.
+func (state *EditorState) DeleteRegion(from_position, to_position int) bool {
+	if to_position > len(state.Content) {
+		to_position = len(state.Content)
+	}
+	if from_position < 0 || from_position >= to_position {
+		return false
+	}
+	removed := append([]AnsiCmd(nil), state.Content[from_position:to_position]...)
+	oldTimings := append([]Timing(nil), state.Timings...)
+	first := state.splitTiming(state.Position2Bytepos(from_position))
+	count := len(state.Timings)
+	last := state.splitTiming(state.Position2Bytepos(to_position))
+	if len(state.Timings) > count {
+		// unlike Cut, the rest of the last chunk keeps its delay, unless the chunk starts before from_position
+		state.Timings[last].Time = state.Timings[last-1].Time
+	}
+	state.Timings = spliceTimings(state.Timings, first, last-first, nil)
+	state.joinTiming(first)
+	state.Content = spliceContent(state.Content, from_position, to_position-from_position, nil)
+	state.record(from_position, removed, nil, oldTimings, Operation{Name: OP_DELETE, From: from_position, To: to_position})
+	return true
+}
 
 func (state *EditorState) ParseTimings(reader *bufio.Reader) {
 	state.Timings = make([]Timing, 0)
@@ -166,6 +237,6 @@ func (state *EditorState) ParseTimings(reader *bufio.Reader) {
 		fmt.Sscanf(string(line), "%f %d", &entry.Time, &entry.Length)
 		state.Timings = append(state.Timings, entry)
 	}
-	_, _, state.Total_time = state.deduceTiming(len(state.Content) - 1)
+	state.changed(0)
 
 }
@@ -3,6 +3,7 @@ package scriptedit
 import (
 	"testing"
 	"bufio"
+	"reflect"
 	"strings"
 )
 
@@ -26,14 +27,14 @@ func (state *EditorState) fullStateDump(t *testing.T) {
 func getPopulatedEditorState(t *testing.T) *EditorState {
 	editorState := NewEditorState()
 	r := bufio.NewReader(strings.NewReader(DOC))
-	editorState.Content = ParseANSI(r)
+	editorState.Content, _ = ParseANSI(r)
 	editorState.Timings = []Timing {Timing{1.1, 6}, Timing{2.3, 16}, Timing{12.1, 5}, Timing{12, 1}, Timing{1, 7}}
 	var l int
 	for _, t := range (editorState.Timings) {
 		l += t.Length
 	}
 	if l != len(DOC) {
-		t.Errorf("Wrong initial state %i != %i", l, len(DOC))
+		t.Errorf("Wrong initial state %d != %d", l, len(DOC))
 	}
 
 	return editorState
@@ -204,3 +205,243 @@ func TestDeleteRegionMiddle(t *testing.T) {
 
 
 }
+
+func TestScreenAtKeyframes(t *testing.T) {
+	state := NewEditorState()
+	doc := strings.Repeat("line of text\r\n\033[1mbold\033[0m", 3000)
+	state.Content = parse(doc)
+	state.Timings = []Timing{Timing{1, len(doc)}}
+
+	for _, position := range []int{40000, 100, KEYFRAME_INTERVAL, 2*KEYFRAME_INTERVAL + 5, 7} {
+		expected := NewScreen(WIDTH, HEIGHT)
+		expected.Feed(state.Content[:position])
+		if state.ScreenAt(position).String() != expected.String() {
+			t.Errorf("Wrong screen at %d", position)
+		}
+	}
+	if len(state.keyframes) != 40000/KEYFRAME_INTERVAL+1 {
+		t.Errorf("Wrong number of keyframes %d", len(state.keyframes))
+	}
+	position, bytepos, _ := state.KeyframeBefore(KEYFRAME_INTERVAL + 10)
+	if position != KEYFRAME_INTERVAL || bytepos != state.Position2Bytepos(position) {
+		t.Errorf("Wrong keyframe %d %d", position, bytepos)
+	}
+
+	state.DeleteRegion(KEYFRAME_INTERVAL+1, KEYFRAME_INTERVAL+3)
+	if len(state.keyframes) != 2 {
+		t.Errorf("Keyframes not invalidated %d", len(state.keyframes))
+	}
+	expected := NewScreen(WIDTH, HEIGHT)
+	expected.Feed(state.Content[:30000])
+	if state.ScreenAt(30000).String() != expected.String() {
+		t.Errorf("Wrong screen after deletion")
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	state := getPopulatedEditorState(t)
+	content := append([]AnsiCmd(nil), state.Content...)
+	timings := append([]Timing(nil), state.Timings...)
+
+	state.DeleteRegion(2, 12)
+	deletedContent := append([]AnsiCmd(nil), state.Content...)
+	deletedTimings := append([]Timing(nil), state.Timings...)
+	state.DeleteRegion(0, 1)
+
+	if !state.Undo() || !state.Undo() {
+		t.Fatal("Could not undo")
+	}
+	if state.Undo() {
+		t.Error("Undo with an empty history")
+	}
+	if !reflect.DeepEqual(state.Content, content) || !reflect.DeepEqual(state.Timings, timings) {
+		t.Errorf("Undo did not restore the original")
+		state.fullStateDump(t)
+	}
+	if state.Total_time != 28.5 {
+		t.Errorf("Wrong total time %f", state.Total_time)
+	}
+
+	if !state.Redo() {
+		t.Fatal("Could not redo")
+	}
+	if !reflect.DeepEqual(state.Content, deletedContent) || !reflect.DeepEqual(state.Timings, deletedTimings) {
+		t.Errorf("Redo did not restore the deletion")
+		state.fullStateDump(t)
+	}
+	state.DeleteRegion(0, 1)
+	if state.Redo() {
+		t.Error("Redo after a new edit")
+	}
+}
+
+func TestMarkersFollowEdits(t *testing.T) {
+	state := getPopulatedEditorState(t)
+	state.AddMarker(20, "after")
+	state.AddMarker(3, "inside")
+	state.AddMarker(1, "before")
+	if state.ToggleMarker(1) || !state.ToggleMarker(1) {
+		t.Errorf("Toggling should remove then add the marker")
+	}
+	state.DeleteRegion(2, 5)
+	expected := []Marker{Marker{1, ""}, Marker{2, "inside"}, Marker{17, "after"}}
+	if !reflect.DeepEqual(state.Markers, expected) {
+		t.Errorf("Wrong markers after delete %v", state.Markers)
+	}
+	state.Undo()
+	if state.Markers[2].Position != 20 {
+		t.Errorf("Wrong markers after undo %v", state.Markers)
+	}
+}
+
+func TestDeleteUpToTheEnd(t *testing.T) {
+	state := getPopulatedEditorState(t)
+	state.DeleteRegion(20, len(state.Content)+1)
+	expected := []Timing{Timing{1.1, 6}, Timing{2.3, 16}, Timing{12.1, 5}}
+	if !reflect.DeepEqual(state.Timings, expected) {
+		t.Errorf("Wrong timings %v", state.Timings)
+	}
+	state.DeleteRegion(len(state.Content), len(state.Content)+1)
+	if len(state.Content) != 20 {
+		t.Errorf("Deleting at the end should not change anything")
+	}
+}
+
+func TestDeleteMultiByteCommands(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("\x1b[31mab\x1b[0mcd" + "efghij" + "klmnop")
+	state.Timings = []Timing{Timing{1, 13}, Timing{1, 6}, Timing{1, 6}}
+	state.DeleteRegion(2, 9)
+	expected := []Timing{Timing{1, 6}, Timing{1, 3}, Timing{1, 6}}
+	if !reflect.DeepEqual(state.Timings, expected) {
+		t.Errorf("Wrong timings %v", state.Timings)
+	}
+	state.Undo()
+	state.DeleteRegion(3, 5)
+	expected = []Timing{Timing{1, 8}, Timing{1, 6}, Timing{1, 6}}
+	if !reflect.DeepEqual(state.Timings, expected) {
+		t.Errorf("Wrong timings inside a chunk %v", state.Timings)
+	}
+}
+
+func TestIdleLimits(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("abcd")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("5 1\n0.5 1\n3 1\n10 1\n")))
+	if changed := state.LimitIdle(2); changed != 3 || state.Total_time != 6.5 {
+		t.Errorf("Expected 3 pauses capped to a total of 6.5 s, got %d %f", changed, state.Total_time)
+	}
+	state.Undo()
+	state.In, state.Out = 1, 3
+	if changed := state.ScaleIdle(1, 0.5); changed != 1 || state.Timings[2].Time != 2 || state.Timings[3].Time != 10 {
+		t.Errorf("Only the selection should be scaled, got %d %v", changed, state.Timings)
+	}
+}
+
+func TestSpeedRegion(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("abcdefgh")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("1 3\n4 3\n2 2\n")))
+	if !state.SpeedRegion(1, 5, 2) {
+		t.Fatalf("The region should be retimed")
+	}
+	expected := []Timing{Timing{1, 1}, Timing{0, 2}, Timing{2, 2}, Timing{0, 1}, Timing{2, 2}}
+	if !reflect.DeepEqual(state.Timings, expected) || state.Total_time != 5 {
+		t.Errorf("Expected %v, got %v %f", expected, state.Timings, state.Total_time)
+	}
+	state.Undo()
+	if state.Total_time != 7 || len(state.Timings) != 3 {
+		t.Errorf("The split chunks should be restored, got %v", state.Timings)
+	}
+}
+
+func TestChunkDelays(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("abcd")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("1 2\n1 2\n")))
+	state.Position = 3
+	state.changed(0)
+	if index := state.CurrentTiming(); index != 1 || !state.SetDelay(index, 0.25) || state.Total_time != 1.25 {
+		t.Errorf("Wrong delay change %d %v", index, state.Timings)
+	}
+	if state.SetDelay(1, 0.25) || !state.Dirty {
+		t.Errorf("Nothing more should change, the first change is unsaved")
+	}
+	if !state.InsertPause(1, 2) || !reflect.DeepEqual(state.Timings, []Timing{Timing{1, 1}, Timing{2, 1}, Timing{0.25, 2}}) {
+		t.Errorf("The pause should split the chunk, got %v", state.Timings)
+	}
+	if !state.InsertPause(4, 1) || state.Total_time != 4.25 || state.Validate() != nil {
+		t.Errorf("The pause at the end should be kept, got %v", state.Timings)
+	}
+}
+
+func TestRetimeTyping(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("$ ls\r\nfile\r\n$ cd")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("0.1 2\n3 1\n0.9 1\n0.5 2\n0.2 4\n0.3 2\n2 2\n")))
+	if changed := state.RetimeTyping(0.1, 0, 1); changed != 1 {
+		t.Errorf("Only the s of ls should be retimed, got %d %v", changed, state.Timings)
+	}
+	expected := []Timing{Timing{0.1, 2}, Timing{3, 1}, Timing{0.1, 1}, Timing{0.5, 2}, Timing{0.2, 4}, Timing{0.3, 2}, Timing{2, 2}}
+	if !reflect.DeepEqual(state.Timings, expected) {
+		t.Errorf("Expected %v, got %v", expected, state.Timings)
+	}
+
+	state.Undo()
+	state.RetimeTyping(0.1, 0.5, 42)
+	jittered := state.Timings[2].Time
+	state.Undo()
+	state.RetimeTyping(0.1, 0.5, 42)
+	if jittered < 0.05 || jittered > 0.15 || state.Timings[2].Time != jittered {
+		t.Errorf("The jitter should be bounded and repeatable, got %f and %f", jittered, state.Timings[2].Time)
+	}
+}
+
+func TestInsertText(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("cd mydir")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("1 3\n1 5\n")))
+	state.Position = 5
+	if !state.InsertText(5, "recto", 0.1) || len(state.Content) != 13 || state.Position != 10 {
+		t.Fatalf("The text should be inserted")
+	}
+	expected := []Timing{Timing{1, 3}, Timing{1, 2}, Timing{0.1, 1}, Timing{0.1, 1}, Timing{0.1, 1}, Timing{0.1, 1}, Timing{0.1, 1}, Timing{0, 3}}
+	if !reflect.DeepEqual(state.Timings, expected) || state.Validate() != nil {
+		t.Errorf("Expected %v, got %v", expected, state.Timings)
+	}
+	state.Undo()
+	if len(state.Content) != 8 || len(state.Timings) != 2 {
+		t.Errorf("The insertion should be undone, got %v", state.Timings)
+	}
+}
+
+func TestCutAndPaste(t *testing.T) {
+	state := NewEditorState()
+	state.Content = parse("abcdefgh")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("1 3\n2 3\n3 2\n")))
+	clip := state.Cut(2, 4)
+	if clip == nil || !reflect.DeepEqual(clip.Timings, []Timing{Timing{0, 1}, Timing{2, 1}}) {
+		t.Fatalf("Wrong clip %v", clip)
+	}
+	if !reflect.DeepEqual(state.Timings, []Timing{Timing{1, 4}, Timing{3, 2}}) || state.Validate() != nil {
+		t.Errorf("The split chunks should be joined again, got %v", state.Timings)
+	}
+	if !state.Paste(6, clip) {
+		t.Fatalf("The clip should be pasted")
+	}
+	var played string
+	for _, ansi := range state.Content {
+		played += ansi.String()
+	}
+	expected := []Timing{Timing{1, 4}, Timing{3, 3}, Timing{2, 1}}
+	if played != "abefghcd" || !reflect.DeepEqual(state.Timings, expected) || state.Total_time != 6 {
+		t.Errorf("Expected abefghcd %v, got %s %v", expected, played, state.Timings)
+	}
+
+	other := NewEditorState()
+	other.Content = parse("xy")
+	other.ParseTimings(bufio.NewReader(strings.NewReader("5 2\n")))
+	if !other.Paste(1, state.Clipboard) || !reflect.DeepEqual(other.Timings, []Timing{Timing{5, 2}, Timing{2, 2}}) {
+		t.Errorf("The clipboard should be pasted in another recording, got %v", other.Timings)
+	}
+}
@@ -0,0 +1,125 @@
+package scriptedit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Explain describes in plain words what the command does.
+func (a AnsiCmd) Explain() string {
+	if a.Code == nil {
+		return fmt.Sprintf("Character %c (%x)", EdulcorateCharacter(a.Letter), a.Letter)
+	}
+	if isStringIntroducer(a.Code.Prefix) {
+		switch a.Code.Prefix {
+		case OSC_CHR:
+			return explainOSC(a.Params)
+		case DCS_CHR:
+			return explainDCS(a.Params)
+		case APC_CHR:
+			if strings.HasPrefix(a.Params, "G") {
+				return fmt.Sprintf("kitty graphics (%d bytes)", len(a.Params))
+			}
+		}
+		return fmt.Sprintf("%s (%d bytes)", a.Code.Explanation, len(a.Params))
+	}
+	if *a.Code == SGR && a.Sequence().Private == "" {
+		return "graphic rendition: " + DescribeSGR(a.Params)
+	}
+	explanation := a.Code.Explanation
+	if a.Params != "" {
+		explanation += " (" + printable(a.Params) + ")"
+	}
+	return explanation
+}
+
+// printable replaces the control characters so s can be displayed in the status area
+func printable(s string) string {
+	return strings.Map(EdulcorateCharacter, s)
+}
+
+var oscExplanations = map[string]string{
+	"0":    "set icon name and window title",
+	"1":    "set icon name",
+	"2":    "set window title",
+	"4":    "change color palette",
+	"7":    "set current directory",
+	"9":    "notification",
+	"10":   "set foreground color",
+	"11":   "set background color",
+	"12":   "set cursor color",
+	"52":   "set clipboard",
+	"104":  "reset color palette",
+	"110":  "reset foreground color",
+	"111":  "reset background color",
+	"112":  "reset cursor color",
+	"633":  "shell integration (VS Code)",
+	"777":  "notification (rxvt)",
+	"1337": "iTerm2 extension",
+}
+
+var promptMarks = map[string]string{
+	"A": "prompt starts",
+	"B": "command line starts",
+	"C": "command output starts",
+	"D": "command finished",
+}
+
+func explainOSC(params string) string {
+	command, data := params, ""
+	if semicolon := strings.IndexByte(params, ';'); semicolon >= 0 {
+		command, data = params[:semicolon], params[semicolon+1:]
+	}
+	switch command {
+	case "8":
+		// OSC 8 ; params ; URI
+		if semicolon := strings.IndexByte(data, ';'); semicolon >= 0 {
+			data = data[semicolon+1:]
+		}
+		if data == "" {
+			return "end of hyperlink"
+		}
+		return "hyperlink to " + printable(data)
+	case "133":
+		mark := data
+		if semicolon := strings.IndexByte(data, ';'); semicolon >= 0 {
+			mark = data[:semicolon]
+		}
+		if explanation, ok := promptMarks[mark]; ok {
+			return "shell integration: " + explanation
+		}
+	case "52":
+		return oscExplanations[command] // do not display its content
+	}
+	if explanation, ok := oscExplanations[command]; ok {
+		if strings.HasSuffix(data, "?") {
+			return strings.Replace(explanation, "set ", "query ", 1)
+		}
+		if data != "" {
+			explanation += ": " + printable(data)
+		}
+		return explanation
+	}
+	return "operating system command (" + printable(params) + ")"
+}
+
+var sixel = regexp.MustCompile(`^[0-9;]*q`)
+
+func explainDCS(params string) string {
+	switch {
+	case strings.HasPrefix(params, "$q"):
+		return "request setting (" + printable(params[2:]) + ")"
+	case strings.HasPrefix(params, "+q"):
+		return "request terminfo capabilities"
+	case strings.HasPrefix(params, "tmux;"):
+		return fmt.Sprintf("tmux passthrough (%d bytes)", len(params)-5)
+	case params == "=1s":
+		return "begin synchronized update"
+	case params == "=2s":
+		return "end synchronized update"
+	case sixel.MatchString(params):
+		return fmt.Sprintf("sixel graphics (%d bytes)", len(params))
+	}
+	return fmt.Sprintf("device control string (%d bytes)", len(params))
+}
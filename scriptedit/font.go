@@ -0,0 +1,118 @@
+package scriptedit
+
+import (
+	"image"
+)
+
+// size of a character cell in the image exports, in pixels
+const CELL_WIDTH = 6
+const CELL_HEIGHT = 10
+
+// FONT_5X7 holds the printable ASCII characters from ' ' to '~', one byte per column,
+// the least significant bit being the top row. Bit 7 is the descender row.
+var FONT_5X7 = [95][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, {0x00, 0x00, 0x5f, 0x00, 0x00}, {0x00, 0x07, 0x00, 0x07, 0x00}, {0x14, 0x7f, 0x14, 0x7f, 0x14},
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, {0x23, 0x13, 0x08, 0x64, 0x62}, {0x36, 0x49, 0x56, 0x20, 0x50}, {0x00, 0x08, 0x07, 0x03, 0x00},
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, {0x00, 0x41, 0x22, 0x1c, 0x00}, {0x2a, 0x1c, 0x7f, 0x1c, 0x2a}, {0x08, 0x08, 0x3e, 0x08, 0x08},
+	{0x00, 0x80, 0x70, 0x30, 0x00}, {0x08, 0x08, 0x08, 0x08, 0x08}, {0x00, 0x00, 0x60, 0x60, 0x00}, {0x20, 0x10, 0x08, 0x04, 0x02},
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, {0x00, 0x42, 0x7f, 0x40, 0x00}, {0x72, 0x49, 0x49, 0x49, 0x46}, {0x21, 0x41, 0x49, 0x4d, 0x33},
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, {0x27, 0x45, 0x45, 0x45, 0x39}, {0x3c, 0x4a, 0x49, 0x49, 0x31}, {0x41, 0x21, 0x11, 0x09, 0x07},
+	{0x36, 0x49, 0x49, 0x49, 0x36}, {0x46, 0x49, 0x49, 0x29, 0x1e}, {0x00, 0x00, 0x14, 0x00, 0x00}, {0x00, 0x40, 0x34, 0x00, 0x00},
+	{0x00, 0x08, 0x14, 0x22, 0x41}, {0x14, 0x14, 0x14, 0x14, 0x14}, {0x00, 0x41, 0x22, 0x14, 0x08}, {0x02, 0x01, 0x59, 0x09, 0x06},
+	{0x3e, 0x41, 0x5d, 0x59, 0x4e}, {0x7c, 0x12, 0x11, 0x12, 0x7c}, {0x7f, 0x49, 0x49, 0x49, 0x36}, {0x3e, 0x41, 0x41, 0x41, 0x22},
+	{0x7f, 0x41, 0x41, 0x41, 0x3e}, {0x7f, 0x49, 0x49, 0x49, 0x41}, {0x7f, 0x09, 0x09, 0x09, 0x01}, {0x3e, 0x41, 0x41, 0x51, 0x73},
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, {0x00, 0x41, 0x7f, 0x41, 0x00}, {0x20, 0x40, 0x41, 0x3f, 0x01}, {0x7f, 0x08, 0x14, 0x22, 0x41},
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, {0x7f, 0x02, 0x1c, 0x02, 0x7f}, {0x7f, 0x04, 0x08, 0x10, 0x7f}, {0x3e, 0x41, 0x41, 0x41, 0x3e},
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, {0x3e, 0x41, 0x51, 0x21, 0x5e}, {0x7f, 0x09, 0x19, 0x29, 0x46}, {0x26, 0x49, 0x49, 0x49, 0x32},
+	{0x03, 0x01, 0x7f, 0x01, 0x03}, {0x3f, 0x40, 0x40, 0x40, 0x3f}, {0x1f, 0x20, 0x40, 0x20, 0x1f}, {0x3f, 0x40, 0x38, 0x40, 0x3f},
+	{0x63, 0x14, 0x08, 0x14, 0x63}, {0x03, 0x04, 0x78, 0x04, 0x03}, {0x61, 0x59, 0x49, 0x4d, 0x43}, {0x00, 0x7f, 0x41, 0x41, 0x41},
+	{0x02, 0x04, 0x08, 0x10, 0x20}, {0x00, 0x41, 0x41, 0x41, 0x7f}, {0x04, 0x02, 0x01, 0x02, 0x04}, {0x40, 0x40, 0x40, 0x40, 0x40},
+	{0x00, 0x03, 0x07, 0x08, 0x00}, {0x20, 0x54, 0x54, 0x78, 0x40}, {0x7f, 0x28, 0x44, 0x44, 0x38}, {0x38, 0x44, 0x44, 0x44, 0x28},
+	{0x38, 0x44, 0x44, 0x28, 0x7f}, {0x38, 0x54, 0x54, 0x54, 0x18}, {0x00, 0x08, 0x7e, 0x09, 0x02}, {0x18, 0xa4, 0xa4, 0x9c, 0x78},
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, {0x00, 0x44, 0x7d, 0x40, 0x00}, {0x20, 0x40, 0x40, 0x3d, 0x00}, {0x7f, 0x10, 0x28, 0x44, 0x00},
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, {0x7c, 0x04, 0x78, 0x04, 0x78}, {0x7c, 0x08, 0x04, 0x04, 0x78}, {0x38, 0x44, 0x44, 0x44, 0x38},
+	{0xfc, 0x18, 0x24, 0x24, 0x18}, {0x18, 0x24, 0x24, 0x18, 0xfc}, {0x7c, 0x08, 0x04, 0x04, 0x08}, {0x48, 0x54, 0x54, 0x54, 0x24},
+	{0x04, 0x04, 0x3f, 0x44, 0x24}, {0x3c, 0x40, 0x40, 0x20, 0x7c}, {0x1c, 0x20, 0x40, 0x20, 0x1c}, {0x3c, 0x40, 0x30, 0x40, 0x3c},
+	{0x44, 0x28, 0x10, 0x28, 0x44}, {0x4c, 0x90, 0x90, 0x90, 0x7c}, {0x44, 0x64, 0x54, 0x4c, 0x44}, {0x00, 0x08, 0x36, 0x41, 0x00},
+	{0x00, 0x00, 0x77, 0x00, 0x00}, {0x00, 0x41, 0x36, 0x08, 0x00}, {0x02, 0x01, 0x02, 0x04, 0x02},
+}
+
+// box drawing characters, by the arms they draw from the centre of the cell
+const (
+	ARM_LEFT = 1 << iota
+	ARM_RIGHT
+	ARM_UP
+	ARM_DOWN
+)
+
+var boxDrawing = map[rune]int{
+	'─': ARM_LEFT | ARM_RIGHT, '━': ARM_LEFT | ARM_RIGHT, '═': ARM_LEFT | ARM_RIGHT,
+	'│': ARM_UP | ARM_DOWN, '┃': ARM_UP | ARM_DOWN, '║': ARM_UP | ARM_DOWN,
+	'┌': ARM_RIGHT | ARM_DOWN, '╭': ARM_RIGHT | ARM_DOWN, '╔': ARM_RIGHT | ARM_DOWN, '┏': ARM_RIGHT | ARM_DOWN,
+	'┐': ARM_LEFT | ARM_DOWN, '╮': ARM_LEFT | ARM_DOWN, '╗': ARM_LEFT | ARM_DOWN, '┓': ARM_LEFT | ARM_DOWN,
+	'└': ARM_RIGHT | ARM_UP, '╰': ARM_RIGHT | ARM_UP, '╚': ARM_RIGHT | ARM_UP, '┗': ARM_RIGHT | ARM_UP,
+	'┘': ARM_LEFT | ARM_UP, '╯': ARM_LEFT | ARM_UP, '╝': ARM_LEFT | ARM_UP, '┛': ARM_LEFT | ARM_UP,
+	'├': ARM_UP | ARM_DOWN | ARM_RIGHT, '╠': ARM_UP | ARM_DOWN | ARM_RIGHT, '┣': ARM_UP | ARM_DOWN | ARM_RIGHT,
+	'┤': ARM_UP | ARM_DOWN | ARM_LEFT, '╣': ARM_UP | ARM_DOWN | ARM_LEFT, '┫': ARM_UP | ARM_DOWN | ARM_LEFT,
+	'┬': ARM_LEFT | ARM_RIGHT | ARM_DOWN, '╦': ARM_LEFT | ARM_RIGHT | ARM_DOWN, '┳': ARM_LEFT | ARM_RIGHT | ARM_DOWN,
+	'┴': ARM_LEFT | ARM_RIGHT | ARM_UP, '╩': ARM_LEFT | ARM_RIGHT | ARM_UP, '┻': ARM_LEFT | ARM_RIGHT | ARM_UP,
+	'┼': ARM_LEFT | ARM_RIGHT | ARM_UP | ARM_DOWN, '╬': ARM_LEFT | ARM_RIGHT | ARM_UP | ARM_DOWN, '╋': ARM_LEFT | ARM_RIGHT | ARM_UP | ARM_DOWN,
+}
+
+// blocks are drawn as the part of the cell they cover, shades as a dithering pattern
+var blocks = map[rune]image.Rectangle{
+	'█': image.Rect(0, 0, CELL_WIDTH, CELL_HEIGHT),
+	'▀': image.Rect(0, 0, CELL_WIDTH, CELL_HEIGHT/2),
+	'▄': image.Rect(0, CELL_HEIGHT/2, CELL_WIDTH, CELL_HEIGHT),
+	'▌': image.Rect(0, 0, CELL_WIDTH/2, CELL_HEIGHT),
+	'▐': image.Rect(CELL_WIDTH/2, 0, CELL_WIDTH, CELL_HEIGHT),
+}
+
+var shades = map[rune]int{'░': 4, '▒': 2, '▓': 1}
+
+// glyph tells which pixels of the cell are drawn with the foreground colour for r.
+func glyph(r rune) func(x, y int) bool {
+	if r >= ' ' && r <= '~' {
+		columns := FONT_5X7[r-' ']
+		return func(x, y int) bool {
+			// one pixel of margin on top and on the right
+			return x < 5 && y >= 1 && y <= 8 && columns[x]&(1<<uint(y-1)) != 0
+		}
+	}
+	if arms, ok := boxDrawing[r]; ok {
+		cx, cy := CELL_WIDTH/2-1, CELL_HEIGHT/2-1
+		return func(x, y int) bool {
+			return (y == cy && (x == cx || x < cx && arms&ARM_LEFT != 0 || x > cx && arms&ARM_RIGHT != 0)) ||
+				(x == cx && (y < cy && arms&ARM_UP != 0 || y > cy && arms&ARM_DOWN != 0))
+		}
+	}
+	if block, ok := blocks[r]; ok {
+		return func(x, y int) bool {
+			return image.Pt(x, y).In(block)
+		}
+	}
+	if every, ok := shades[r]; ok {
+		return func(x, y int) bool {
+			return (x+y*3)%every == 0
+		}
+	}
+	switch r {
+	case 0, '\u00a0':
+		return func(x, y int) bool { return false }
+	case '·', '•':
+		return func(x, y int) bool { return x >= 1 && x <= 2 && y >= 4 && y <= 5 }
+	case '◆', '♦':
+		return func(x, y int) bool { return abs(x-2)+abs(y-4) <= 2 }
+	}
+	// unknown characters are drawn as an empty box
+	return func(x, y int) bool {
+		return x < 5 && y >= 1 && y <= 7 && (x == 0 || x == 4 || y == 1 || y == 7)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
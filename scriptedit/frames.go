@@ -0,0 +1,39 @@
+package scriptedit
+
+// FINAL_FRAME_DURATION is how long the exports keep the last screen displayed, in seconds
+const FINAL_FRAME_DURATION = 2
+
+// Frame is what the terminal displays between two chunks of output.
+type Frame struct {
+	Screen   *Screen // shared between the frames, clone it to keep it
+	Position int     // index in the Content of the next command to play
+	Time     float32 // when the frame starts to be displayed
+	Duration float32
+}
+
+// Frames plays the recording on a headless screen and calls display after every chunk of the Timings.
+// It stops at the first error returned by display.
+func (state *EditorState) Frames(display func(frame Frame) error) error {
+	screen := NewScreen(state.TerminalSize())
+	position, bytepos, played := 0, 0, 0
+	var now float32
+	for index, timing := range state.Timings {
+		now += timing.Time
+		played += timing.Length
+		last := index == len(state.Timings)-1
+		// the commands that start in the chunk are displayed with it, the last chunk takes what is left
+		for position < len(state.Content) && (bytepos < played || last) {
+			bytepos += len(state.Content[position].String())
+			screen.Apply(state.Content[position])
+			position++
+		}
+		duration := float32(FINAL_FRAME_DURATION)
+		if !last {
+			duration = state.Timings[index+1].Time
+		}
+		if err := display(Frame{screen, position, now, duration}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
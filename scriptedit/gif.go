@@ -0,0 +1,134 @@
+package scriptedit
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// MIN_GIF_DELAY is the shortest frame duration the browsers honour, in 1/100 s
+const MIN_GIF_DELAY = 2
+
+// rasterizer paints screen cells with the bitmap font.
+type rasterizer struct {
+	palette *Palette
+	colors  color.Palette
+	indexes map[color.RGBA]uint8
+}
+
+func newRasterizer(palette *Palette) *rasterizer {
+	return &rasterizer{palette, palette.Indexed(), make(map[color.RGBA]uint8)}
+}
+
+func (r *rasterizer) index(c color.RGBA) uint8 {
+	index, ok := r.indexes[c]
+	if !ok {
+		index = uint8(r.colors.Index(c))
+		r.indexes[c] = index
+	}
+	return index
+}
+
+// paint renders the cells of area, in cells, to a new image.
+func (r *rasterizer) paint(screen *Screen, area image.Rectangle) *image.Paletted {
+	img := image.NewPaletted(image.Rect(area.Min.X*CELL_WIDTH, area.Min.Y*CELL_HEIGHT, area.Max.X*CELL_WIDTH, area.Max.Y*CELL_HEIGHT), r.colors)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			cell := Cell{' ', DEFAULT_ATTR, false}
+			if y < screen.Height && x < screen.Width {
+				cell = screen.Cells[y][x]
+			}
+			fg, bg := r.palette.Colors(cell.Attr)
+			if screen.CursorVisible && x == screen.X && y == screen.Y {
+				fg, bg = bg, fg
+			}
+			foreground, background := r.index(fg), r.index(bg)
+			draw := glyph(cell.Ch)
+			if cell.Tail || cell.Attr.Hidden {
+				draw = glyph(0)
+			}
+			for py := 0; py < CELL_HEIGHT; py++ {
+				for px := 0; px < CELL_WIDTH; px++ {
+					on := draw(px, py) ||
+						cell.Attr.Bold && px > 0 && draw(px-1, py) ||
+						cell.Attr.Underline != UNDERLINE_NONE && py == CELL_HEIGHT-1 ||
+						cell.Attr.Strike && py == CELL_HEIGHT/2-1 ||
+						cell.Attr.Overline && py == 0
+					index := background
+					if on {
+						index = foreground
+					}
+					img.SetColorIndex(x*CELL_WIDTH+px, y*CELL_HEIGHT+py, index)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// changedArea returns the cells that differ between the two screens, cursor included.
+func changedArea(previous, current *Screen) image.Rectangle {
+	area := image.Rectangle{}
+	add := func(x, y int) {
+		area = area.Union(image.Rect(x, y, x+1, y+1))
+	}
+	for y := 0; y < current.Height; y++ {
+		for x := 0; x < current.Width; x++ {
+			if y >= previous.Height || x >= previous.Width || previous.Cells[y][x] != current.Cells[y][x] {
+				add(x, y)
+			}
+		}
+	}
+	if previous.CursorVisible && (previous.X != current.X || previous.Y != current.Y || !current.CursorVisible) {
+		add(previous.X, previous.Y)
+	}
+	if current.CursorVisible && (previous.X != current.X || previous.Y != current.Y || !previous.CursorVisible) {
+		add(current.X, current.Y)
+	}
+	return area
+}
+
+// WriteGIF renders the recording as an animated GIF using the Timings as frame durations.
+// Each frame only stores the part of the screen that changed.
+func (state *EditorState) WriteGIF(writer io.Writer, palette *Palette) error {
+	raster := newRasterizer(palette)
+	width, height := state.TerminalSize()
+	bounds := image.Rect(0, 0, width, height)
+	animation := &gif.GIF{Config: image.Config{ColorModel: raster.colors, Width: width * CELL_WIDTH, Height: height * CELL_HEIGHT}}
+
+	var previous *Screen
+	shown := 0 // in 1/100 s
+	err := state.Frames(func(frame Frame) error {
+		end := int((frame.Time+frame.Duration)*100 + 0.5)
+		area := bounds
+		if previous != nil {
+			area = changedArea(previous, frame.Screen).Intersect(bounds)
+			if area.Empty() {
+				// nothing new is displayed, the previous image stays longer
+				animation.Delay[len(animation.Delay)-1] += end - shown
+				shown = end
+				return nil
+			}
+		}
+		if end-int(frame.Time*100+0.5) < MIN_GIF_DELAY {
+			// too short to be seen, its changes come with the next frame
+			return nil
+		}
+		animation.Image = append(animation.Image, raster.paint(frame.Screen, area))
+		animation.Delay = append(animation.Delay, end-shown)
+		animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+		shown = end
+		previous = frame.Screen.Clone()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(animation.Image) == 0 {
+		animation.Image = append(animation.Image, raster.paint(NewScreen(width, height), bounds))
+		animation.Delay = append(animation.Delay, FINAL_FRAME_DURATION*100)
+		animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+	}
+	return gif.EncodeAll(writer, animation)
+}
@@ -0,0 +1,87 @@
+package scriptedit
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func recording(t *testing.T, doc string, timings []Timing) *EditorState {
+	state := NewEditorState()
+	content, err := ParseANSI(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	state.Content = content
+	state.Timings = timings
+	state.Width, state.Height = 10, 3
+	state.changed(0)
+	return state
+}
+
+func TestFrames(t *testing.T) {
+	state := recording(t, "ab\033[1;1Hc", []Timing{Timing{1, 1}, Timing{0.5, 1}, Timing{0.25, 6}})
+	var lines []string
+	var times []float32
+	state.Frames(func(frame Frame) error {
+		lines = append(lines, frame.Screen.Line(0))
+		times = append(times, frame.Time, frame.Duration)
+		return nil
+	})
+	if !reflect.DeepEqual(lines, []string{"a", "ab", "cb"}) {
+		t.Errorf("Wrong frames %q", lines)
+	}
+	if !reflect.DeepEqual(times, []float32{1, 0.5, 1.5, 0.25, 1.75, FINAL_FRAME_DURATION}) {
+		t.Errorf("Wrong times %v", times)
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	state := recording(t, "\033[?25lab\033[31mc\033[0md", []Timing{Timing{0, 7}, Timing{0.001, 1}, Timing{0.5, 6}, Timing{1, 5}, Timing{0.5, 0}})
+	var output bytes.Buffer
+	if err := state.WriteGIF(&output, &XTERM_PALETTE); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	animation, err := gif.DecodeAll(&output)
+	if err != nil {
+		t.Fatalf("Invalid GIF %s", err)
+	}
+	if animation.Config.Width != 10*CELL_WIDTH || animation.Config.Height != 3*CELL_HEIGHT {
+		t.Errorf("Wrong size %dx%d", animation.Config.Width, animation.Config.Height)
+	}
+	// "a" is displayed too shortly to get its own frame, the last timing does not change the screen
+	if !reflect.DeepEqual(animation.Delay, []int{50, 100, 50 + FINAL_FRAME_DURATION*100}) {
+		t.Errorf("Wrong delays %v", animation.Delay)
+	}
+	if bounds := animation.Image[1].Bounds(); bounds != image.Rect(2*CELL_WIDTH, 0, 3*CELL_WIDTH, CELL_HEIGHT) {
+		t.Errorf("Wrong delta frame %v", bounds)
+	}
+	// the middle of the vertical stroke of "c" uses the red
+	if c := animation.Image[1].At(2*CELL_WIDTH, 4); c != color.Color(XTERM_PALETTE.ANSI[1]) {
+		t.Errorf("Wrong colour %v", c)
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	if palette, err := ParsePalette("vga"); err != nil || palette != &VGA_PALETTE {
+		t.Errorf("Wrong named palette")
+	}
+	spec := "#ffffff,#000000" + strings.Repeat(",102030", 16)
+	palette, err := ParsePalette(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if palette.Foreground != rgb(0xffffff) || palette.ANSI[15] != rgb(0x102030) {
+		t.Errorf("Wrong palette %v", palette)
+	}
+	if _, err := ParsePalette("#ffffff,#000000"); err == nil {
+		t.Errorf("An incomplete palette should be rejected")
+	}
+	if len(palette.Indexed()) != 256 {
+		t.Errorf("Wrong indexed palette size %d", len(palette.Indexed()))
+	}
+}
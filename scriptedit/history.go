@@ -0,0 +1,126 @@
+package scriptedit
+
+// edit is a reversible change of the recording: Content[position:position+len(removed)]
+// has been replaced by inserted and Timings[timingIndex:timingIndex+len(removedTimings)]
+// by insertedTimings.
+type edit struct {
+	position        int
+	removed         []AnsiCmd
+	inserted        []AnsiCmd
+	timingIndex     int
+	removedTimings  []Timing
+	insertedTimings []Timing
+	operation       Operation // how to make it again, see project.go
+}
+
+// record pushes on the undo stack the change that has just been made at position.
+// oldTimings is a copy of the Timings before the change, only the part that differs is kept.
+// op describes the change for the edit decision list.
+func (state *EditorState) record(position int, removed, inserted []AnsiCmd, oldTimings []Timing, op Operation) {
+	prefix := 0
+	for prefix < len(oldTimings) && prefix < len(state.Timings) && oldTimings[prefix] == state.Timings[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldTimings)-prefix && suffix < len(state.Timings)-prefix &&
+		oldTimings[len(oldTimings)-1-suffix] == state.Timings[len(state.Timings)-1-suffix] {
+		suffix++
+	}
+	e := edit{
+		position:        position,
+		removed:         removed,
+		inserted:        append([]AnsiCmd(nil), inserted...),
+		timingIndex:     prefix,
+		removedTimings:  append([]Timing(nil), oldTimings[prefix:len(oldTimings)-suffix]...),
+		insertedTimings: append([]Timing(nil), state.Timings[prefix:len(state.Timings)-suffix]...),
+		operation:       op,
+	}
+	state.undoStack = append(state.undoStack, e)
+	state.redoStack = nil
+	state.Dirty = true
+	state.journal(op)
+	state.shiftMarkers(position, len(removed), len(inserted))
+	state.changed(position)
+}
+
+// changed refreshes everything derived from the Content and Timings after a modification at position
+func (state *EditorState) changed(position int) {
+	state.invalidateScreens(position)
+	state.updateTotalTime()
+	if state.Position > len(state.Content) {
+		state.Position = len(state.Content)
+	}
+	state.Bytepos = state.Position2Bytepos(state.Position)
+	_, _, state.Time = state.deduceTiming(state.Bytepos)
+}
+
+func (state *EditorState) updateTotalTime() {
+	state.Total_time = 0
+	for _, t := range state.Timings {
+		state.Total_time += t.Time
+	}
+}
+
+func spliceContent(content []AnsiCmd, position, length int, replacement []AnsiCmd) []AnsiCmd {
+	result := make([]AnsiCmd, 0, len(content)-length+len(replacement))
+	result = append(result, content[:position]...)
+	result = append(result, replacement...)
+	return append(result, content[position+length:]...)
+}
+
+func spliceTimings(timings []Timing, index, length int, replacement []Timing) []Timing {
+	result := make([]Timing, 0, len(timings)-length+len(replacement))
+	result = append(result, timings[:index]...)
+	result = append(result, replacement...)
+	return append(result, timings[index+length:]...)
+}
+
+// Forget drops the undo and redo stacks, once the edits are part of the recording itself.
+func (state *EditorState) Forget() {
+	state.undoStack = nil
+	state.redoStack = nil
+}
+
+func (state *EditorState) CanUndo() bool {
+	return len(state.undoStack) > 0
+}
+
+func (state *EditorState) CanRedo() bool {
+	return len(state.redoStack) > 0
+}
+
+// Undo reverts the last edit and moves the Position where it happened.
+func (state *EditorState) Undo() bool {
+	if len(state.undoStack) == 0 {
+		return false
+	}
+	e := state.undoStack[len(state.undoStack)-1]
+	state.undoStack = state.undoStack[:len(state.undoStack)-1]
+	state.Content = spliceContent(state.Content, e.position, len(e.inserted), e.removed)
+	state.shiftMarkers(e.position, len(e.inserted), len(e.removed))
+	state.Timings = spliceTimings(state.Timings, e.timingIndex, len(e.insertedTimings), e.removedTimings)
+	state.redoStack = append(state.redoStack, e)
+	state.Dirty = true
+	state.journal(Operation{Name: OP_UNDO, From: -1, To: -1})
+	state.Position = e.position
+	state.changed(e.position)
+	return true
+}
+
+// Redo applies again the last undone edit.
+func (state *EditorState) Redo() bool {
+	if len(state.redoStack) == 0 {
+		return false
+	}
+	e := state.redoStack[len(state.redoStack)-1]
+	state.redoStack = state.redoStack[:len(state.redoStack)-1]
+	state.Content = spliceContent(state.Content, e.position, len(e.removed), e.inserted)
+	state.shiftMarkers(e.position, len(e.removed), len(e.inserted))
+	state.Timings = spliceTimings(state.Timings, e.timingIndex, len(e.removedTimings), e.insertedTimings)
+	state.undoStack = append(state.undoStack, e)
+	state.Dirty = true
+	state.journal(Operation{Name: OP_REDO, From: -1, To: -1})
+	state.Position = e.position
+	state.changed(e.position)
+	return true
+}
@@ -0,0 +1,298 @@
+package scriptedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlFrame only holds the lines that changed since the previous frame
+type htmlFrame struct {
+	Time  float32        `json:"t"`
+	Lines map[int]string `json:"l"`
+}
+
+type htmlChapter struct {
+	Time  float32 `json:"t"`
+	Label string  `json:"label"`
+}
+
+type htmlRecording struct {
+	Width    int           `json:"width"`
+	Height   int           `json:"height"`
+	Duration float32       `json:"duration"`
+	Frames   []htmlFrame   `json:"frames"`
+	Chapters []htmlChapter `json:"chapters"`
+}
+
+// htmlLine renders a line of the screen as spans of the same colours.
+func htmlLine(screen *Screen, y int, palette *Palette) string {
+	var buffer bytes.Buffer
+	line := screen.Cells[y]
+	for x := 0; x < len(line); {
+		fg, bg := cellColors(screen, x, y, palette)
+		style := cellStyle(screen, x, y, palette)
+		var text bytes.Buffer
+		end := x
+		for ; end < len(line) && cellStyle(screen, end, y, palette) == style; end++ {
+			if _, next := cellColors(screen, end, y, palette); next != bg {
+				break
+			}
+			if line[end].Tail {
+				continue
+			}
+			if line[end].Attr.Hidden || line[end].Ch == 0 {
+				text.WriteRune(' ')
+			} else {
+				text.WriteRune(line[end].Ch)
+			}
+		}
+		var css []string
+		if fg != palette.Foreground {
+			css = append(css, "color:"+hex(fg))
+		}
+		if bg != palette.Background {
+			css = append(css, "background:"+hex(bg))
+		}
+		if style.bold {
+			css = append(css, "font-weight:bold")
+		}
+		if style.italic {
+			css = append(css, "font-style:italic")
+		}
+		var decorations []string
+		if style.underline {
+			decorations = append(decorations, "underline")
+		}
+		if style.strike {
+			decorations = append(decorations, "line-through")
+		}
+		if style.over {
+			decorations = append(decorations, "overline")
+		}
+		if len(decorations) > 0 {
+			css = append(css, "text-decoration:"+strings.Join(decorations, " "))
+		}
+		if len(css) > 0 {
+			fmt.Fprintf(&buffer, `<span style="%s">%s</span>`, strings.Join(css, ";"), html.EscapeString(text.String()))
+		} else {
+			buffer.WriteString(html.EscapeString(text.String()))
+		}
+		x = end
+	}
+	return strings.TrimRight(buffer.String(), " ")
+}
+
+// WriteHTML writes a standalone web page replaying the recording, with its chapters.
+// The screens are computed here and stored as the lines that change, the page only has to display them.
+func (state *EditorState) WriteHTML(writer io.Writer, palette *Palette, title string) error {
+	width, height := state.TerminalSize()
+	recording := htmlRecording{Width: width, Height: height}
+	var previous []string
+	err := state.Frames(func(frame Frame) error {
+		lines := make(map[int]string)
+		for y := 0; y < frame.Screen.Height && y < height; y++ {
+			line := htmlLine(frame.Screen, y, palette)
+			if y >= len(previous) || previous[y] != line {
+				lines[y] = line
+			}
+			if y < len(previous) {
+				previous[y] = line
+			} else {
+				previous = append(previous, line)
+			}
+		}
+		recording.Duration = frame.Time + frame.Duration
+		if len(lines) > 0 || len(recording.Frames) == 0 {
+			recording.Frames = append(recording.Frames, htmlFrame{frame.Time, lines})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(recording.Frames) > 0 {
+		recording.Frames[0].Time = 0
+	}
+	recording.Chapters = make([]htmlChapter, 0, len(state.Markers))
+	for _, marker := range state.Markers {
+		recording.Chapters = append(recording.Chapters, htmlChapter{state.MarkerTime(marker), marker.Label})
+	}
+	data, err := json.Marshal(recording) // <, > and & are escaped so it can be embedded in the script
+	if err != nil {
+		return err
+	}
+
+	if title == "" {
+		title = "screencast"
+	}
+	replacer := strings.NewReplacer(
+		"{{TITLE}}", html.EscapeString(title),
+		"{{FOREGROUND}}", hex(palette.Foreground),
+		"{{BACKGROUND}}", hex(palette.Background),
+		"{{RECORDING}}", string(data),
+	)
+	_, err = replacer.WriteString(writer, HTML_PLAYER)
+	return err
+}
+
+const HTML_PLAYER = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{TITLE}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+#terminal { display: inline-block; padding: 0.5em; margin: 0; font: 13px/1.25 monospace; white-space: pre; color: {{FOREGROUND}}; background: {{BACKGROUND}}; }
+#controls { display: flex; align-items: center; gap: 0.5em; margin: 0.5em 0; }
+#seek { flex: 1; }
+#chapters a { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>{{TITLE}}</h1>
+<pre id="terminal"></pre>
+<div id="controls">
+<button id="play">Play</button>
+<input id="seek" type="range" min="0" step="0.01" value="0">
+<span id="time"></span>
+<select id="speed"><option value="0.5">0.5x</option><option value="1" selected>1x</option><option value="2">2x</option><option value="4">4x</option></select>
+</div>
+<ol id="chapters"></ol>
+<script>
+(function() {
+var recording = {{RECORDING}};
+var terminal = document.getElementById("terminal");
+var play = document.getElementById("play");
+var seek = document.getElementById("seek");
+var time = document.getElementById("time");
+var speed = document.getElementById("speed");
+var rows = [];
+var frame = -1;
+var current = 0;
+var playing = false;
+var startedAt = 0, startedFrom = 0;
+
+for (var y = 0; y < recording.height; y++) {
+	var row = document.createElement("div");
+	row.style.minHeight = "1.25em";
+	rows.push(row);
+	terminal.appendChild(row);
+}
+terminal.style.width = recording.width + "ch";
+seek.max = recording.duration;
+
+function format(seconds) {
+	var minutes = Math.floor(seconds / 60);
+	seconds = Math.floor(seconds % 60);
+	return minutes + ":" + (seconds < 10 ? "0" : "") + seconds;
+}
+
+// display the frames up to index, replaying them from the start to go back
+function show(index) {
+	if (index < frame) {
+		for (var y = 0; y < rows.length; y++) {
+			rows[y].innerHTML = "";
+		}
+		frame = -1;
+	}
+	while (frame < index) {
+		frame++;
+		var lines = recording.frames[frame].l;
+		for (var y in lines) {
+			rows[y].innerHTML = lines[y];
+		}
+	}
+}
+
+function frameAt(t) {
+	var low = 0, high = recording.frames.length - 1;
+	while (low < high) {
+		var middle = Math.ceil((low + high) / 2);
+		if (recording.frames[middle].t <= t) {
+			low = middle;
+		} else {
+			high = middle - 1;
+		}
+	}
+	return low;
+}
+
+function goTo(t) {
+	current = Math.max(0, Math.min(t, recording.duration));
+	show(frameAt(current));
+	seek.value = current;
+	time.textContent = format(current) + " / " + format(recording.duration);
+	if (playing) {
+		startedAt = performance.now();
+		startedFrom = current;
+	}
+}
+
+function tick() {
+	if (!playing) {
+		return;
+	}
+	var t = startedFrom + (performance.now() - startedAt) / 1000 * parseFloat(speed.value);
+	if (t >= recording.duration) {
+		goTo(recording.duration);
+		pause();
+		return;
+	}
+	goTo(t);
+	startedFrom = t;
+	requestAnimationFrame(tick);
+}
+
+function start() {
+	if (current >= recording.duration) {
+		current = 0;
+	}
+	playing = true;
+	play.textContent = "Pause";
+	goTo(current);
+	requestAnimationFrame(tick);
+}
+
+function pause() {
+	playing = false;
+	play.textContent = "Play";
+}
+
+play.onclick = function() {
+	playing ? pause() : start();
+};
+seek.oninput = function() {
+	goTo(parseFloat(seek.value));
+};
+speed.onchange = function() {
+	goTo(current);
+};
+document.addEventListener("keydown", function(event) {
+	if (event.key == " " && event.target.tagName != "BUTTON") {
+		event.preventDefault();
+		playing ? pause() : start();
+	}
+});
+
+var chapters = document.getElementById("chapters");
+recording.chapters.forEach(function(chapter, index) {
+	var link = document.createElement("a");
+	link.textContent = format(chapter.t) + " " + (chapter.label || "Chapter " + (index + 1));
+	link.onclick = function() {
+		goTo(chapter.t);
+	};
+	var item = document.createElement("li");
+	item.appendChild(link);
+	chapters.appendChild(item);
+});
+
+goTo(0);
+})();
+</script>
+</body>
+</html>
+`
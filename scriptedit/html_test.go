@@ -0,0 +1,46 @@
+package scriptedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	state := recording(t, "\033[?25la\033[31m<b\033[0m\r\nc", []Timing{Timing{1, 7}, Timing{1, 10}, Timing{1, 1}, Timing{1, 3}})
+	state.AddMarker(len(state.Content)-1, "last <line>")
+	var output bytes.Buffer
+	if err := state.WriteHTML(&output, &XTERM_PALETTE, "Demo & co"); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	page := output.String()
+	if !strings.Contains(page, "<title>Demo &amp; co</title>") {
+		t.Errorf("Wrong title")
+	}
+	start := strings.Index(page, "var recording = ") + len("var recording = ")
+	end := strings.Index(page[start:], ";\n") + start
+	var recording htmlRecording
+	if err := json.Unmarshal([]byte(page[start:end]), &recording); err != nil {
+		t.Fatalf("Invalid recording %s: %s", err, page[start:end])
+	}
+	if strings.Contains(page[start:end], "<") {
+		t.Errorf("The recording could close the script")
+	}
+	if recording.Width != 10 || recording.Height != 3 || recording.Duration != 4+FINAL_FRAME_DURATION {
+		t.Errorf("Wrong recording %+v", recording)
+	}
+	// the carriage return does not change the screen
+	expected := []htmlFrame{
+		htmlFrame{0, map[int]string{0: "a", 1: "", 2: ""}},
+		htmlFrame{2, map[int]string{0: `a<span style="color:#cd0000">&lt;b</span>`}},
+		htmlFrame{4, map[int]string{1: "c"}},
+	}
+	if !reflect.DeepEqual(recording.Frames, expected) {
+		t.Errorf("Wrong frames %v", recording.Frames)
+	}
+	if !reflect.DeepEqual(recording.Chapters, []htmlChapter{htmlChapter{4, "last <line>"}}) {
+		t.Errorf("Wrong chapters %v", recording.Chapters)
+	}
+}
@@ -0,0 +1,29 @@
+package scriptedit
+
+import (
+	"strings"
+)
+
+// InsertText adds text at position as if it had been typed, each command of it output interval
+// seconds after the previous one. The Position follows the text when it was at position, like a cursor.
+// It returns false if text is not valid.
+func (state *EditorState) InsertText(position int, text string, interval float32) bool {
+	bytepos := state.Position2Bytepos(position)
+	inserted, err := ParseANSI(strings.NewReader(text))
+	if bytepos < 0 || err != nil || len(inserted) == 0 {
+		return false
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	timings := make([]Timing, len(inserted))
+	for i, ansi := range inserted {
+		timings[i] = Timing{interval, len(ansi.String())}
+	}
+	index := state.splitTiming(bytepos)
+	state.Timings = spliceTimings(state.Timings, index, 0, timings)
+	state.Content = spliceContent(state.Content, position, 0, inserted)
+	if state.Position == position {
+		state.Position += len(inserted)
+	}
+	state.record(position, nil, inserted, oldTimings, Operation{Name: OP_INSERT, From: position, To: position, Seconds: interval, Text: []byte(text)})
+	return true
+}
@@ -0,0 +1,58 @@
+package scriptedit
+
+import (
+	"sort"
+)
+
+// Marker is a chapter of the recording starting with the command at Position.
+type Marker struct {
+	Position int
+	Label    string
+}
+
+// AddMarker adds a chapter at position, keeping the Markers sorted.
+func (state *EditorState) AddMarker(position int, label string) {
+	index := sort.Search(len(state.Markers), func(i int) bool { return state.Markers[i].Position > position })
+	state.Markers = append(state.Markers, Marker{})
+	copy(state.Markers[index+1:], state.Markers[index:])
+	state.Markers[index] = Marker{position, label}
+	state.Dirty = true
+}
+
+// ToggleMarker removes the markers at position, or adds one if there was none.
+// It returns true if a marker was added.
+func (state *EditorState) ToggleMarker(position int) bool {
+	state.journal(Operation{Name: OP_MARKER, From: position, To: position})
+	kept := state.Markers[:0]
+	for _, marker := range state.Markers {
+		if marker.Position != position {
+			kept = append(kept, marker)
+		}
+	}
+	if len(kept) < len(state.Markers) {
+		state.Markers = kept
+		state.Dirty = true
+		return false
+	}
+	state.AddMarker(position, "")
+	return true
+}
+
+// MarkerTime returns when the chapter starts in the replay.
+func (state *EditorState) MarkerTime(marker Marker) float32 {
+	_, _, time := state.deduceTiming(state.Position2Bytepos(marker.Position))
+	return time
+}
+
+// shiftMarkers follows the replacement of removed commands by inserted ones at position.
+// The markers of the removed commands move to position.
+func (state *EditorState) shiftMarkers(position, removed, inserted int) {
+	for i := range state.Markers {
+		marker := &state.Markers[i]
+		if marker.Position >= position+removed {
+			marker.Position += inserted - removed
+		} else if marker.Position > position {
+			marker.Position = position
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package scriptedit
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Palette gives the RGB values of the terminal colours for the exports.
+type Palette struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	ANSI       [16]color.RGBA // the 8 normal and 8 bright colours
+}
+
+func rgb(value uint32) color.RGBA {
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xff}
+}
+
+var XTERM_PALETTE = Palette{rgb(0xe5e5e5), rgb(0x000000), [16]color.RGBA{
+	rgb(0x000000), rgb(0xcd0000), rgb(0x00cd00), rgb(0xcdcd00), rgb(0x0000ee), rgb(0xcd00cd), rgb(0x00cdcd), rgb(0xe5e5e5),
+	rgb(0x7f7f7f), rgb(0xff0000), rgb(0x00ff00), rgb(0xffff00), rgb(0x5c5cff), rgb(0xff00ff), rgb(0x00ffff), rgb(0xffffff),
+}}
+
+var VGA_PALETTE = Palette{rgb(0xaaaaaa), rgb(0x000000), [16]color.RGBA{
+	rgb(0x000000), rgb(0xaa0000), rgb(0x00aa00), rgb(0xaa5500), rgb(0x0000aa), rgb(0xaa00aa), rgb(0x00aaaa), rgb(0xaaaaaa),
+	rgb(0x555555), rgb(0xff5555), rgb(0x55ff55), rgb(0xffff55), rgb(0x5555ff), rgb(0xff55ff), rgb(0x55ffff), rgb(0xffffff),
+}}
+
+var SOLARIZED_PALETTE = Palette{rgb(0x839496), rgb(0x002b36), [16]color.RGBA{
+	rgb(0x073642), rgb(0xdc322f), rgb(0x859900), rgb(0xb58900), rgb(0x268bd2), rgb(0xd33682), rgb(0x2aa198), rgb(0xeee8d5),
+	rgb(0x002b36), rgb(0xcb4b16), rgb(0x586e75), rgb(0x657b83), rgb(0x839496), rgb(0x6c71c4), rgb(0x93a1a1), rgb(0xfdf6e3),
+}}
+
+var PALETTES = map[string]*Palette{
+	"xterm":     &XTERM_PALETTE,
+	"vga":       &VGA_PALETTE,
+	"solarized": &SOLARIZED_PALETTE,
+}
+
+// ParsePalette returns one of the PALETTES by name, or reads a comma separated list
+// of 18 hex colours: foreground, background and the 16 ANSI colours.
+func ParsePalette(spec string) (*Palette, error) {
+	if palette, ok := PALETTES[spec]; ok {
+		return palette, nil
+	}
+	values := strings.Split(spec, ",")
+	if len(values) != 18 {
+		names := make([]string, 0, len(PALETTES))
+		for name := range PALETTES {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("palette: expected one of %s or 18 colours", strings.Join(names, ", "))
+	}
+	colors := make([]color.RGBA, len(values))
+	for i, value := range values {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+		parsed, err := strconv.ParseUint(value, 16, 32)
+		if err != nil || len(value) != 6 {
+			return nil, fmt.Errorf("palette: invalid colour %q", values[i])
+		}
+		colors[i] = rgb(uint32(parsed))
+	}
+	palette := &Palette{Foreground: colors[0], Background: colors[1]}
+	copy(palette.ANSI[:], colors[2:])
+	return palette, nil
+}
+
+// RGBA resolves c, the default colour being the foreground or the background one.
+func (p *Palette) RGBA(c Color, foreground bool) color.RGBA {
+	switch {
+	case c.IsDefault() && foreground:
+		return p.Foreground
+	case c.IsDefault():
+		return p.Background
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return color.RGBA{r, g, b, 0xff}
+	case c < 16:
+		return p.ANSI[c]
+	case c < 232:
+		// 6x6x6 colour cube
+		levels := [6]uint8{0, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+		c -= 16
+		return color.RGBA{levels[c/36], levels[c/6%6], levels[c%6], 0xff}
+	}
+	grey := uint8(8 + 10*(c-232))
+	return color.RGBA{grey, grey, grey, 0xff}
+}
+
+// Colors returns the foreground and background colours of a cell painted with attr.
+func (p *Palette) Colors(attr Attr) (color.RGBA, color.RGBA) {
+	fg, bg := p.RGBA(attr.Fg, true), p.RGBA(attr.Bg, false)
+	if attr.Faint {
+		fg = color.RGBA{uint8((int(fg.R) + int(bg.R)) / 2), uint8((int(fg.G) + int(bg.G)) / 2), uint8((int(fg.B) + int(bg.B)) / 2), 0xff}
+	}
+	if attr.Reverse {
+		fg, bg = bg, fg
+	}
+	return fg, bg
+}
+
+// Indexed returns a 256 colours palette for the paletted images: the default colours,
+// the 16 ANSI ones, the colour cube and most of the grey ramp. Other colours are approximated.
+func (p *Palette) Indexed() color.Palette {
+	palette := color.Palette{p.Background, p.Foreground}
+	for c := Color(0); len(palette) < 256; c++ {
+		if c == 232 || c == 255 { // almost black and white
+			continue
+		}
+		palette = append(palette, p.RGBA(c, true))
+	}
+	return palette
+}
@@ -0,0 +1,132 @@
+package scriptedit
+
+import (
+	"bytes"
+	"time"
+)
+
+// Player replays a recording chunk by chunk, following its Timings.
+type Player struct {
+	Speed   float32 // 2 plays twice as fast
+	MaxIdle float32 // the delays are capped to it, in seconds, 0 for no cap
+
+	state           *EditorState
+	raw             []byte // the content as it is played
+	chunk           int    // index of the next timing to play
+	offset          int    // offset where the next timing starts
+	bytepos         int    // offset of the next byte to play, after offset when started in the middle of a chunk
+	time            float32
+	position        int // the commands completely played
+	positionBytepos int
+}
+
+func NewPlayer(state *EditorState) *Player {
+	var content bytes.Buffer
+	for _, ansi := range state.Content {
+		content.WriteString(ansi.String())
+	}
+	return &Player{Speed: 1, state: state, raw: content.Bytes()}
+}
+
+// Time returns when the last played chunk was output in the recording.
+func (p *Player) Time() float32 {
+	return p.time
+}
+
+// Position returns the index of the first command that has not been completely played.
+func (p *Player) Position() int {
+	return p.position
+}
+
+// Bytepos returns the raw offset of Position.
+func (p *Player) Bytepos() int {
+	return p.positionBytepos
+}
+
+// Done tells if everything has been played.
+func (p *Player) Done() bool {
+	return p.chunk >= len(p.state.Timings)
+}
+
+// Next returns the next chunk of output and how long to wait before displaying it.
+// It returns false at the end of the recording.
+func (p *Player) Next() (time.Duration, []byte, bool) {
+	if p.Done() {
+		return 0, nil, false
+	}
+	timing := p.state.Timings[p.chunk]
+	p.chunk++
+	p.time += timing.Time
+	delay := timing.Time
+	if p.MaxIdle > 0 && delay > p.MaxIdle {
+		delay = p.MaxIdle
+	}
+	if p.bytepos > p.offset {
+		delay = 0 // the beginning of the chunk is already displayed
+	}
+	p.offset += timing.Length
+	end := p.offset
+	if end > len(p.raw) || p.Done() {
+		end = len(p.raw) // the last chunk takes what is left
+	}
+	if end < p.bytepos {
+		end = p.bytepos
+	}
+	data := p.raw[p.bytepos:end]
+	p.bytepos = end
+	p.advance()
+	return time.Duration(float64(delay) / float64(p.Speed) * float64(time.Second)), data, true
+}
+
+// advance moves the Position after the commands that have been completely played.
+func (p *Player) advance() {
+	for p.position < len(p.state.Content) {
+		length := len(p.state.Content[p.position].String())
+		if p.positionBytepos+length > p.bytepos {
+			break
+		}
+		p.positionBytepos += length
+		p.position++
+	}
+}
+
+func (p *Player) rewind() {
+	p.chunk, p.offset, p.bytepos, p.time = 0, 0, 0, 0
+	p.position, p.positionBytepos = 0, 0
+}
+
+// Seek moves the player to time, the chunks output until then being considered as played.
+// It returns what redraws the screen as it is at that time.
+func (p *Player) Seek(at float32) []byte {
+	p.rewind()
+	for p.chunk < len(p.state.Timings) && p.time+p.state.Timings[p.chunk].Time <= at {
+		p.time += p.state.Timings[p.chunk].Time
+		p.offset += p.state.Timings[p.chunk].Length
+		p.chunk++
+	}
+	p.bytepos = p.offset
+	if p.bytepos > len(p.raw) || p.Done() {
+		p.bytepos = len(p.raw)
+	}
+	p.advance()
+	// the screen of the last complete command, then the beginning of the one that is cut
+	var output bytes.Buffer
+	output.WriteString(p.state.ScreenAt(p.position).Render())
+	output.Write(p.raw[p.positionBytepos:p.bytepos])
+	return output.Bytes()
+}
+
+// SeekPosition moves the player to the command at position, when the screen already displays what is before it.
+func (p *Player) SeekPosition(position int) {
+	p.rewind()
+	p.bytepos = p.state.Position2Bytepos(position)
+	if p.bytepos < 0 {
+		p.bytepos = len(p.raw)
+	}
+	for p.chunk < len(p.state.Timings) && p.offset+p.state.Timings[p.chunk].Length <= p.bytepos {
+		p.time += p.state.Timings[p.chunk].Time
+		p.offset += p.state.Timings[p.chunk].Length
+		p.chunk++
+	}
+	p.advance()
+}
@@ -0,0 +1,39 @@
+package scriptedit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayerNext(t *testing.T) {
+	state := recording(t, "abcdef", []Timing{Timing{1, 2}, Timing{10, 3}, Timing{0.5, 1}})
+	player := NewPlayer(state)
+	player.Speed = 2
+	player.MaxIdle = 3
+	expected := []struct {
+		delay time.Duration
+		data  string
+	}{{500 * time.Millisecond, "ab"}, {1500 * time.Millisecond, "cde"}, {250 * time.Millisecond, "f"}}
+	for _, e := range expected {
+		delay, data, ok := player.Next()
+		if !ok || delay != e.delay || string(data) != e.data {
+			t.Errorf("Expected %s %q, got %s %q %v", e.delay, e.data, delay, data, ok)
+		}
+	}
+	if _, _, ok := player.Next(); ok || !player.Done() || player.Time() != 11.5 {
+		t.Errorf("The player should be done at 11.5, got %f", player.Time())
+	}
+}
+
+func TestPlayerSeek(t *testing.T) {
+	state := recording(t, "ab\033[31mcd", []Timing{Timing{1, 3}, Timing{1, 4}, Timing{1, 2}})
+	player := NewPlayer(state)
+	screen := NewScreen(state.TerminalSize())
+	screen.Feed(parse(string(player.Seek(2.5))))
+	if player.Time() != 2 || screen.Render() != state.ScreenAt(state.TimeToPosition(3)).Render() {
+		t.Errorf("Wrong screen after seeking at %f\n%q", player.Time(), screen.Render())
+	}
+	if _, data, _ := player.Next(); string(data) != "cd" {
+		t.Errorf("The replay should go on after the seek, got %q", data)
+	}
+}
@@ -0,0 +1,298 @@
+package scriptedit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const PROJECT_EXTENSION = ".edl"
+const PROJECT_VERSION = 1
+const JOURNAL_EXTENSION = ".journal"
+
+// the operations of an edit decision list
+const (
+	OP_DELETE       = "delete"
+	OP_CUT          = "cut"
+	OP_PASTE        = "paste"
+	OP_INSERT       = "insert"
+	OP_SPEED        = "speed"
+	OP_SPEED_REGION = "speed-region"
+	OP_LIMIT_IDLE   = "limit-idle"
+	OP_SCALE_IDLE   = "scale-idle"
+	OP_TYPING       = "typing"
+	OP_SET_DELAY    = "set-delay"
+	OP_INSERT_PAUSE = "insert-pause"
+	OP_MARKER       = "marker" // only in the journals, like the next ones
+	OP_UNDO         = "undo"
+	OP_REDO         = "redo"
+)
+
+// Operation describes an edit well enough to do it again on the same recording.
+// From and To are positions in the Content as it was when the edit was made, -1 for no In/Out selection.
+type Operation struct {
+	Name    string   `json:"op"`
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Index   int      `json:"index,omitempty"`
+	Seconds float32  `json:"seconds,omitempty"`
+	Factor  float32  `json:"factor,omitempty"`
+	Jitter  float32  `json:"jitter,omitempty"`
+	Seed    int64    `json:"seed,omitempty"`
+	Text    []byte   `json:"text,omitempty"` // base64, the output is not always UTF-8
+	Timings []Timing `json:"timings,omitempty"`
+}
+
+// Project is an edit decision list: the edits to apply, in order, to a recording that stays untouched.
+type Project struct {
+	Version int         `json:"version"`
+	Source  string      `json:"source"` // the recording, relative to the project file
+	Edits   []Operation `json:"edits"`
+	Markers []Marker    `json:"markers,omitempty"` // the chapters of the edited recording
+}
+
+func (op Operation) String() string {
+	region := fmt.Sprintf("from %d to %d", op.From, op.To)
+	if op.From < 0 {
+		region = "everywhere"
+	}
+	switch op.Name {
+	case OP_DELETE, OP_CUT:
+		return fmt.Sprintf("%s %s", op.Name, region)
+	case OP_PASTE:
+		return fmt.Sprintf("paste %d bytes at %d", len(op.Text), op.From)
+	case OP_INSERT:
+		return fmt.Sprintf("insert %q at %d", op.Text, op.From)
+	case OP_SPEED:
+		return fmt.Sprintf("speed x%g", op.Factor)
+	case OP_SPEED_REGION:
+		return fmt.Sprintf("speed x%g %s", op.Factor, region)
+	case OP_LIMIT_IDLE:
+		return fmt.Sprintf("limit the pauses to %g s %s", op.Seconds, region)
+	case OP_SCALE_IDLE:
+		return fmt.Sprintf("scale the pauses above %g s by %g %s", op.Seconds, op.Factor, region)
+	case OP_TYPING:
+		return fmt.Sprintf("type every %g s (jitter %g, seed %d) %s", op.Seconds, op.Jitter, op.Seed, region)
+	case OP_SET_DELAY:
+		return fmt.Sprintf("set the delay of chunk %d to %g s", op.Index, op.Seconds)
+	case OP_INSERT_PAUSE:
+		return fmt.Sprintf("insert a pause of %g s at %d", op.Seconds, op.From)
+	case OP_MARKER:
+		return fmt.Sprintf("toggle the chapter at %d", op.From)
+	}
+	return op.Name
+}
+
+// Operations returns the edits made since the recording was loaded, the undone ones excluded.
+func (state *EditorState) Operations() []Operation {
+	operations := make([]Operation, 0, len(state.undoStack))
+	for _, e := range state.undoStack {
+		operations = append(operations, e.operation)
+	}
+	return operations
+}
+
+// Without returns the operations of the edits without the one at index, the following ones moved
+// to where they apply once it is gone: their positions and chunk indexes after what it changed shift
+// by what it added or removed. It fails when one of them changes what the removed edit made.
+func (state *EditorState) Without(index int) ([]Operation, error) {
+	if index < 0 || index >= len(state.undoStack) {
+		return nil, fmt.Errorf("no edit %d", index+1)
+	}
+	removed := state.undoStack[index]
+	// what the removed edit made, as it moves with the following edits
+	start, end := removed.position, removed.position+len(removed.inserted)
+	first, last := removed.timingIndex, removed.timingIndex+len(removed.insertedTimings)
+	shift := len(removed.removed) - len(removed.inserted)
+	timingShift := len(removed.removedTimings) - len(removed.insertedTimings)
+
+	operations := make([]Operation, 0, len(state.undoStack)-1)
+	for i, e := range state.undoStack {
+		op := e.operation
+		if i > index {
+			conflict := op.From >= 0 && overlaps(op.From, op.To, start, end)
+			if op.Name == OP_SET_DELAY {
+				conflict = conflict || overlaps(op.Index, op.Index+1, first, last)
+				op.Index, _ = rebase(op.Index, op.Index+1, last, timingShift)
+			}
+			if conflict {
+				return nil, fmt.Errorf("edit %d (%s) changes what edit %d made", i+1, op, index+1)
+			}
+			if op.From >= 0 {
+				op.From, op.To = rebase(op.From, op.To, end, shift)
+			}
+			start, end = follow(start, end, e.position, e.position+len(e.removed), len(e.inserted)-len(e.removed))
+			first, last = follow(first, last, e.timingIndex, e.timingIndex+len(e.removedTimings), len(e.insertedTimings)-len(e.removedTimings))
+		}
+		if i != index {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+// overlaps tells if [from, to) changes [start, end), an empty range is a point between two positions.
+func overlaps(from, to, start, end int) bool {
+	if start == end {
+		return from < start && start < to
+	}
+	if from == to {
+		return start < from && from < end
+	}
+	return from < end && start < to
+}
+
+// rebase moves [from, to) by shift when it is after end.
+func rebase(from, to, end, shift int) (int, int) {
+	if from == to && from >= end {
+		return from + shift, to + shift
+	}
+	if from >= end {
+		from += shift
+	}
+	if to > end {
+		to += shift
+	}
+	return from, to
+}
+
+// follow moves [start, end) like an edit replacing [from, to) with delta more elements.
+func follow(start, end, from, to, delta int) (int, int) {
+	if from >= end && (from > start || start == end) {
+		return start, end // after it
+	}
+	if to <= start {
+		return start + delta, end + delta
+	}
+	if from < start {
+		start = from
+	}
+	if to > end {
+		end = to
+	}
+	return start, end + delta
+}
+
+// Apply does op again, as a new edit. It fails when op does not apply to the recording
+// as it is, it has then not been changed.
+func (state *EditorState) Apply(op Operation) error {
+	if op.From < -1 || op.To < -1 || (op.From < 0) != (op.To < 0) || op.To < op.From {
+		return fmt.Errorf("%s: invalid region", op)
+	}
+	if op.From > len(state.Content) || op.To > len(state.Content) {
+		return fmt.Errorf("%s: the recording is too short", op)
+	}
+	in, out := state.In, state.Out
+	defer func() {
+		state.In, state.Out = in, out
+	}()
+	state.In, state.Out = op.From, op.To // the selection of the retimes
+	var applied bool
+	switch op.Name {
+	case OP_DELETE:
+		applied = state.DeleteRegion(op.From, op.To)
+	case OP_CUT:
+		applied = state.Cut(op.From, op.To) != nil
+	case OP_PASTE:
+		content, err := ParseANSI(bytes.NewReader(op.Text))
+		if err != nil {
+			return fmt.Errorf("%s: %s", op, err)
+		}
+		applied = op.From >= 0 && state.Paste(op.From, &Clip{content, op.Timings})
+	case OP_INSERT:
+		applied = op.From >= 0 && state.InsertText(op.From, string(op.Text), op.Seconds)
+	case OP_SPEED:
+		if applied = op.Factor > 0; applied {
+			state.Speed(op.Factor)
+		}
+	case OP_SPEED_REGION:
+		applied = state.SpeedRegion(op.From, op.To, op.Factor)
+	case OP_LIMIT_IDLE:
+		applied = state.LimitIdle(op.Seconds) > 0
+	case OP_SCALE_IDLE:
+		applied = state.ScaleIdle(op.Seconds, op.Factor) > 0
+	case OP_TYPING:
+		applied = state.RetimeTyping(op.Seconds, op.Jitter, op.Seed) > 0
+	case OP_SET_DELAY:
+		if op.Index < 0 || op.Index >= len(state.Timings) {
+			return fmt.Errorf("%s: no such chunk", op)
+		}
+		applied = state.SetDelay(op.Index, op.Seconds)
+	case OP_INSERT_PAUSE:
+		applied = op.From >= 0 && state.InsertPause(op.From, op.Seconds)
+	case OP_MARKER:
+		applied = op.From >= 0
+		if applied {
+			state.ToggleMarker(op.From)
+		}
+	case OP_UNDO:
+		applied = state.Undo()
+	case OP_REDO:
+		applied = state.Redo()
+	default:
+		return fmt.Errorf("unknown operation %s", op.Name)
+	}
+	if !applied {
+		return fmt.Errorf("%s: does not apply to the recording", op)
+	}
+	return nil
+}
+
+// ReadProject reads an edit decision list written by WriteProject.
+func ReadProject(reader io.Reader) (*Project, error) {
+	project := new(Project)
+	if err := json.NewDecoder(reader).Decode(project); err != nil {
+		return nil, err
+	}
+	if project.Version != PROJECT_VERSION {
+		return nil, fmt.Errorf("unsupported project version %d", project.Version)
+	}
+	if strings.HasSuffix(project.Source, PROJECT_EXTENSION) {
+		return nil, fmt.Errorf("the source %s is a project, not a recording", project.Source)
+	}
+	return project, nil
+}
+
+// WriteProject writes the edits made to the recording source as an edit decision list.
+func (state *EditorState) WriteProject(writer io.Writer, source string) error {
+	data, err := json.MarshalIndent(Project{PROJECT_VERSION, source, state.Operations(), state.Markers}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+// journal appends op to the Journal, one JSON object per line.
+func (state *EditorState) journal(op Operation) {
+	if state.Journal == nil {
+		return
+	}
+	if data, err := json.Marshal(op); err == nil {
+		state.Journal.Write(append(data, '\n'))
+	}
+}
+
+// ReadJournal reads the operations written in a Journal, they can be made again with Apply.
+// A last line cut by a crash is ignored.
+func ReadJournal(reader io.Reader) ([]Operation, error) {
+	var operations []Operation
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err == io.EOF {
+			return operations, nil
+		}
+		if err != nil {
+			return operations, err
+		}
+		var op Operation
+		if err := json.Unmarshal(line, &op); err != nil {
+			return operations, err
+		}
+		operations = append(operations, op)
+	}
+}
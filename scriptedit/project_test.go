@@ -0,0 +1,174 @@
+package scriptedit
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func projectRecording() *EditorState {
+	state := NewEditorState()
+	state.Content = parse("$ lss\r\nfile\r\n$ cd dir\r\n")
+	state.ParseTimings(bufio.NewReader(strings.NewReader("0.1 2\n3 1\n0.9 1\n0.5 1\n0.2 2\n0.3 4\n4 2\n0.2 2\n0.2 1\n0.2 1\n0.2 1\n0.2 1\n0.2 3\n")))
+	return state
+}
+
+func TestProjectReplay(t *testing.T) {
+	state := projectRecording()
+	state.DeleteRegion(4, 5)
+	state.In, state.Out = 6, 14
+	state.LimitIdle(1)
+	state.In, state.Out = -1, -1
+	state.InsertText(14, "ir", 0.1)
+	state.SpeedRegion(0, 4, 2)
+	state.Copy(0, 2)
+	state.Paste(len(state.Content), state.Clipboard)
+	state.SetDelay(1, 0.5)
+	state.InsertPause(3, 1)
+	state.RetimeTyping(0.05, 0.2, 7)
+	state.Speed(1.5)
+	state.Undo()
+
+	var output bytes.Buffer
+	if err := state.WriteProject(&output, "demo.cast"); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	project, err := ReadProject(&output)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if project.Source != "demo.cast" || len(project.Edits) != 8 || project.Edits[0].String() != "delete from 4 to 5" {
+		t.Errorf("Wrong project %+v", project)
+	}
+
+	replayed := projectRecording()
+	for _, op := range project.Edits {
+		if err := replayed.Apply(op); err != nil {
+			t.Fatalf("Unexpected error %s", err)
+		}
+	}
+	if !reflect.DeepEqual(replayed.Content, state.Content) || !reflect.DeepEqual(replayed.Timings, state.Timings) {
+		t.Errorf("The edits should give the same recording\n%v\n%v", replayed.Timings, state.Timings)
+	}
+	if replayed.Apply(Operation{Name: "explode"}) == nil {
+		t.Errorf("An unknown operation should be rejected")
+	}
+}
+
+func TestProjectInvalidEdits(t *testing.T) {
+	state := projectRecording()
+	for _, op := range []Operation{
+		Operation{Name: OP_DELETE, From: -5, To: 2},
+		Operation{Name: OP_CUT, From: 4, To: 2},
+		Operation{Name: OP_DELETE, From: 2, To: 2},
+		Operation{Name: OP_PASTE, From: 0, To: 0},
+		Operation{Name: OP_INSERT_PAUSE, From: -1, To: -1, Seconds: 1},
+		Operation{Name: OP_SET_DELAY, From: -1, To: -1, Index: -3, Seconds: 1},
+		Operation{Name: OP_SET_DELAY, From: -1, To: -1, Index: 100, Seconds: 1},
+		Operation{Name: OP_LIMIT_IDLE, From: -1, To: -1, Seconds: 10},
+		Operation{Name: OP_UNDO, From: -1, To: -1},
+	} {
+		if state.Apply(op) == nil {
+			t.Errorf("%+v should be rejected", op)
+		}
+	}
+	if state.CanUndo() {
+		t.Errorf("The rejected edits should not change the recording")
+	}
+}
+
+func TestProjectBinaryText(t *testing.T) {
+	state := projectRecording()
+	state.InsertText(0, "\xff\xfe ok", 0.1)
+	var output bytes.Buffer
+	state.WriteProject(&output, "demo")
+	project, err := ReadProject(&output)
+	if err != nil || string(project.Edits[0].Text) != "\xff\xfe ok" {
+		t.Errorf("The inserted bytes should be kept, got %+v %v", project, err)
+	}
+	if _, err = ReadProject(strings.NewReader(`{"version":1,"source":"other.edl"}`)); err == nil {
+		t.Errorf("A project of a project should be rejected")
+	}
+}
+
+func TestProjectWithout(t *testing.T) {
+	replay := func(source string, timings []Timing, operations []Operation) *EditorState {
+		state := recording(t, source, timings)
+		for _, op := range operations {
+			if err := state.Apply(op); err != nil {
+				t.Fatalf("Unexpected error %s", err)
+			}
+		}
+		return state
+	}
+	one := func(length int) []Timing {
+		return []Timing{Timing{1, length}}
+	}
+	state := replay("0123456789ABCDEFGHIJ", one(20), nil)
+	state.DeleteRegion(0, 5)
+	state.DeleteRegion(5, 10)
+	operations, err := state.Without(0)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if replayed := replay("0123456789ABCDEFGHIJ", one(20), operations); !reflect.DeepEqual(replayed.Content, parse("0123456789FGHIJ")) {
+		t.Errorf("The second delete should still remove ABCDE, got %v", replayed.Content)
+	}
+
+	state = replay("0123456789ABCDEFGHIJ", []Timing{Timing{1, 10}, Timing{1, 10}}, nil)
+	state.InsertPause(5, 1)
+	state.SetDelay(2, 3)
+	if operations, err = state.Without(0); err != nil || operations[0].Index != 1 {
+		t.Errorf("The chunk of the delay should move back, got %v %v", operations, err)
+	}
+
+	state = replay("0123456789", one(10), nil)
+	state.InsertText(2, "xyz", 0.1)
+	state.DeleteRegion(3, 8)
+	if _, err = state.Without(0); err == nil {
+		t.Errorf("Deleting a part of the inserted text depends on it")
+	}
+}
+
+func TestProjectMarkers(t *testing.T) {
+	state := projectRecording()
+	state.AddMarker(3, "ls")
+	if !state.Dirty {
+		t.Errorf("A new chapter should be unsaved")
+	}
+	var output bytes.Buffer
+	state.WriteProject(&output, "demo")
+	project, err := ReadProject(&output)
+	if err != nil || len(project.Edits) != 0 || !reflect.DeepEqual(project.Markers, state.Markers) {
+		t.Errorf("The chapters should be saved in the project, got %+v %v", project, err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	var journal bytes.Buffer
+	state := projectRecording()
+	state.Journal = &journal
+	state.DeleteRegion(4, 5)
+	state.ToggleMarker(2)
+	state.InsertText(4, "x", 0.1)
+	state.Undo()
+	state.Redo()
+	state.Undo()
+	journal.WriteString(`{"op":"speed","fr`) // cut by a crash
+
+	operations, err := ReadJournal(&journal)
+	if err != nil || len(operations) != 6 || operations[3].Name != OP_UNDO {
+		t.Fatalf("Wrong journal %v %s", operations, err)
+	}
+	recovered := projectRecording()
+	for _, op := range operations {
+		if err := recovered.Apply(op); err != nil {
+			t.Fatalf("Unexpected error %s", err)
+		}
+	}
+	if !reflect.DeepEqual(recovered.Content, state.Content) || !reflect.DeepEqual(recovered.Markers, state.Markers) || !recovered.CanRedo() {
+		t.Errorf("The journal should give back the same recording")
+	}
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package scriptedit
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl constants
+const (
+	TIOCGWINSZ = 0x5413
+	TIOCSWINSZ = 0x5414
+	TIOCGPTN   = 0x80045430
+	TIOCSPTLCK = 0x40045431
+)
+
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// GetWindowSize returns the number of columns and lines of the terminal.
+func (ttyfd TTY) GetWindowSize() (int, int, error) {
+	var size winsize
+	err := ioctl(uintptr(ttyfd), TIOCGWINSZ, unsafe.Pointer(&size))
+	return int(size.cols), int(size.rows), err
+}
+
+func (ttyfd TTY) SetWindowSize(width, height int) error {
+	size := winsize{rows: uint16(height), cols: uint16(width)}
+	return ioctl(uintptr(ttyfd), TIOCSWINSZ, unsafe.Pointer(&size))
+}
+
+// StartInPty starts cmd with a new width x height pseudo-terminal as its controlling terminal.
+// It returns the master side of the pseudo-terminal to talk to the command.
+func StartInPty(cmd *exec.Cmd, width, height int) (*os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unlock int32
+	var number uint32
+	if err = ioctl(master.Fd(), TIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+		err = ioctl(master.Fd(), TIOCGPTN, unsafe.Pointer(&number))
+	}
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(number)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	defer slave.Close() // the command has its own copy
+
+	if err = TTY(slave.Fd()).SetWindowSize(width, height); err == nil {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+		err = cmd.Start()
+	}
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package scriptedit
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStartInPty(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "stty size")
+	master, err := StartInPty(cmd, 100, 30)
+	if err != nil {
+		t.Skipf("No pseudo-terminal available: %s", err)
+	}
+	defer master.Close()
+	output, _ := ioutil.ReadAll(master) // ends with EIO when the command exits
+	cmd.Wait()
+	if strings.TrimSpace(string(output)) != "30 100" {
+		t.Errorf("Wrong terminal size %q", output)
+	}
+}
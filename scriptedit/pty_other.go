@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package scriptedit
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoPty = errors.New("pseudo-terminals are only supported on Linux")
+
+func (ttyfd TTY) GetWindowSize() (int, int, error) {
+	return 0, 0, errNoPty
+}
+
+func (ttyfd TTY) SetWindowSize(width, height int) error {
+	return errNoPty
+}
+
+func StartInPty(cmd *exec.Cmd, width, height int) (*os.File, error) {
+	return nil, errNoPty
+}
@@ -0,0 +1,70 @@
+package scriptedit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder is a Writer keeping what is written with the delays between the writes.
+// It can be written from several goroutines.
+type Recorder struct {
+	mutex   sync.Mutex
+	content bytes.Buffer
+	timings []Timing
+	last    time.Time
+	start   string // the size of the terminal before it was resized
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{last: time.Now()}
+}
+
+func (r *Recorder) Write(data []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	r.timings = append(r.timings, Timing{float32(now.Sub(r.last).Seconds()), len(data)})
+	r.last = now
+	return r.content.Write(data)
+}
+
+// Resize records that the terminal went from oldWidth x oldHeight to width x height, with the sequence
+// resizing it. The recording then starts with the sequence of the size it had first.
+func (r *Recorder) Resize(oldWidth, oldHeight, width, height int) {
+	r.mutex.Lock()
+	if r.start == "" {
+		r.start = fmt.Sprintf(CHANGE_SIZE, oldHeight, oldWidth)
+	}
+	r.mutex.Unlock()
+	r.Write([]byte(fmt.Sprintf(CHANGE_SIZE, height, width)))
+}
+
+func (r *Recorder) Bytes() []byte {
+	return r.content.Bytes()
+}
+
+func (r *Recorder) Timings() []Timing {
+	return r.timings
+}
+
+// Recording replaces the recording of state by what has been written.
+// Like ParseANSI, a *SyntaxError means it has malformed sequences but is loaded anyway.
+func (r *Recorder) Recording(state *EditorState) error {
+	content, err := ParseANSI(io.MultiReader(strings.NewReader(r.start), bytes.NewReader(r.content.Bytes())))
+	if _, malformed := err.(*SyntaxError); err != nil && !malformed {
+		return err
+	}
+	state.Content = content
+	state.Timings = append([]Timing(nil), r.timings...)
+	if r.start != "" {
+		state.Timings = append([]Timing{Timing{0, len(r.start)}}, state.Timings...)
+	}
+	state.undoStack, state.redoStack = nil, nil
+	state.Markers = nil
+	state.changed(0)
+	return err
+}
@@ -0,0 +1,60 @@
+package scriptedit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Write([]byte("ab"))
+	time.Sleep(20 * time.Millisecond)
+	recorder.Write([]byte("\033[1mc"))
+
+	state := NewEditorState()
+	if err := recorder.Recording(state); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if len(state.Content) != 4 || len(state.Timings) != 2 {
+		t.Fatalf("Wrong recording %v %v", state.Content, state.Timings)
+	}
+	if state.Timings[1].Length != 5 || state.Timings[1].Time < 0.02 || state.Timings[0].Time > state.Timings[1].Time {
+		t.Errorf("Wrong timings %v", state.Timings)
+	}
+}
+
+func TestScriptHeader(t *testing.T) {
+	header := ScriptHeader(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "xterm-256color", 100, 30)
+	if !strings.HasPrefix(header, "Script started on 2026-01-02 03:04:05+00:00 [") || !strings.HasSuffix(header, "]\n") {
+		t.Errorf("Wrong header %q", header)
+	}
+	if width, height := ParseScriptHeader(header); width != 100 || height != 30 {
+		t.Errorf("Wrong size %dx%d", width, height)
+	}
+	if width, height := ParseScriptHeader("Script started on Thu 01 Jan 2026\n"); width != 0 || height != 0 {
+		t.Errorf("Wrong size %dx%d", width, height)
+	}
+}
+
+func TestRecorderResize(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Write([]byte("ab"))
+	recorder.Resize(10, 3, 20, 5)
+	recorder.Write([]byte("c"))
+
+	state := NewEditorState()
+	state.Width, state.Height = 20, 5
+	if err := recorder.Recording(state); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if len(state.Timings) != 4 || state.Timings[0].Time != 0 || state.Content[0].String() != "\033[8;3;10t" {
+		t.Fatalf("The recording should start at the first size %v %v", state.Content, state.Timings)
+	}
+	if first := state.ScreenAt(2); first.Width != 10 || first.Height != 3 {
+		t.Errorf("Wrong first size %dx%d", first.Width, first.Height)
+	}
+	if last := state.ScreenAt(len(state.Content)); last.Width != 20 || last.Height != 5 {
+		t.Errorf("Wrong last size %dx%d", last.Width, last.Height)
+	}
+}
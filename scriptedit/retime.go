@@ -0,0 +1,164 @@
+package scriptedit
+
+// Speed divides every delay of the recording by factor.
+func (state *EditorState) Speed(factor float32) {
+	oldTimings := append([]Timing(nil), state.Timings...)
+	for i := range state.Timings {
+		state.Timings[i].Time /= factor
+	}
+	state.record(state.Position, nil, nil, oldTimings, Operation{Name: OP_SPEED, From: -1, To: -1, Factor: factor})
+}
+
+// selection returns the In/Out selection, -1 -1 when there is none.
+func (state *EditorState) selection() (int, int) {
+	if state.In < 0 || state.Out <= state.In {
+		return -1, -1
+	}
+	return state.In, state.Out
+}
+
+// selectedTimings returns the indexes [first, last) of the Timings that output something
+// of the In/Out selection, all of them when there is no selection.
+func (state *EditorState) selectedTimings() (int, int) {
+	if in, _ := state.selection(); in < 0 {
+		return 0, len(state.Timings)
+	}
+	start, end := state.Position2Bytepos(state.In), state.Position2Bytepos(state.Out)
+	if end < 0 {
+		end = state.Position2Bytepos(len(state.Content))
+	}
+	first, last := len(state.Timings), 0
+	var offset int
+	for index, t := range state.Timings {
+		if offset < end && (offset+t.Length > start || (t.Length == 0 && offset >= start)) {
+			if index < first {
+				first = index
+			}
+			last = index + 1
+		}
+		offset += t.Length
+	}
+	if first > last {
+		return 0, 0
+	}
+	return first, last
+}
+
+// retime replaces the delays of the selected timings by what change returns.
+// It returns how many of them changed, the whole modification is a single undoable edit.
+func (state *EditorState) retime(op Operation, change func(delay float32) float32) int {
+	oldTimings := append([]Timing(nil), state.Timings...)
+	first, last := state.selectedTimings()
+	changed := 0
+	for i := first; i < last; i++ {
+		if delay := change(state.Timings[i].Time); delay != state.Timings[i].Time {
+			state.Timings[i].Time = delay
+			changed++
+		}
+	}
+	if changed > 0 {
+		op.From, op.To = state.selection()
+		state.record(state.Position, nil, nil, oldTimings, op)
+	}
+	return changed
+}
+
+// LimitIdle caps the delays to max seconds, only inside the In/Out selection when there is one.
+// It returns how many pauses were shortened.
+func (state *EditorState) LimitIdle(max float32) int {
+	return state.retime(Operation{Name: OP_LIMIT_IDLE, Seconds: max}, func(delay float32) float32 {
+		if delay > max {
+			return max
+		}
+		return delay
+	})
+}
+
+// ScaleIdle multiplies by factor the part of the delays above threshold seconds, only inside
+// the In/Out selection when there is one. It returns how many pauses were changed.
+func (state *EditorState) ScaleIdle(threshold, factor float32) int {
+	return state.retime(Operation{Name: OP_SCALE_IDLE, Seconds: threshold, Factor: factor}, func(delay float32) float32 {
+		if delay > threshold {
+			return threshold + (delay-threshold)*factor
+		}
+		return delay
+	})
+}
+
+// splitTiming makes a timing start at bytepos, cutting the one that outputs it in two.
+// The delay stays with the first part. It returns the index of the timing starting at bytepos.
+func (state *EditorState) splitTiming(bytepos int) int {
+	var offset int
+	for index, t := range state.Timings {
+		if offset == bytepos {
+			return index
+		}
+		if offset+t.Length > bytepos {
+			state.Timings = spliceTimings(state.Timings, index, 1, []Timing{Timing{t.Time, bytepos - offset}, Timing{0, offset + t.Length - bytepos}})
+			return index + 1
+		}
+		offset += t.Length
+	}
+	return len(state.Timings)
+}
+
+// SpeedRegion divides by factor the delays of Content[from:to], the chunks crossing its
+// boundaries are split so that nothing outside of it changes.
+func (state *EditorState) SpeedRegion(from, to int, factor float32) bool {
+	if to > len(state.Content) {
+		to = len(state.Content)
+	}
+	if from < 0 || from >= to || factor <= 0 {
+		return false
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	first := state.splitTiming(state.Position2Bytepos(from))
+	last := state.splitTiming(state.Position2Bytepos(to))
+	for i := first; i < last; i++ {
+		state.Timings[i].Time /= factor
+	}
+	state.record(from, nil, nil, oldTimings, Operation{Name: OP_SPEED_REGION, From: from, To: to, Factor: factor})
+	return true
+}
+
+// CurrentTiming returns the index of the timing that outputs the command at Position, -1 if there is none.
+func (state *EditorState) CurrentTiming() int {
+	if len(state.Timings) == 0 {
+		return -1
+	}
+	index, _, _ := state.deduceTiming(state.Bytepos)
+	return index
+}
+
+// SetDelay changes how long the timing at index waits before its output, negative delays become 0.
+func (state *EditorState) SetDelay(index int, delay float32) bool {
+	if index < 0 || index >= len(state.Timings) {
+		return false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay == state.Timings[index].Time {
+		return false
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	state.Timings[index].Time = delay
+	state.record(state.Position, nil, nil, oldTimings, Operation{Name: OP_SET_DELAY, From: -1, To: -1, Index: index, Seconds: delay})
+	return true
+}
+
+// InsertPause waits seconds more before the command at position, splitting its chunk if needed.
+func (state *EditorState) InsertPause(position int, seconds float32) bool {
+	bytepos := state.Position2Bytepos(position)
+	if bytepos < 0 || seconds <= 0 {
+		return false
+	}
+	oldTimings := append([]Timing(nil), state.Timings...)
+	index := state.splitTiming(bytepos)
+	if index == len(state.Timings) {
+		state.Timings = append(state.Timings, Timing{0, 0}) // a pause at the end
+	}
+	state.Timings[index].Time += seconds
+	state.record(position, nil, nil, oldTimings, Operation{Name: OP_INSERT_PAUSE, From: position, To: position, Seconds: seconds})
+	return true
+}
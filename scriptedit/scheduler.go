@@ -0,0 +1,176 @@
+package scriptedit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Chunk is a part of the output to display, with where the replay is once it is displayed.
+type Chunk struct {
+	Data     []byte
+	Position int // the first command not completely displayed
+	Bytepos  int // the raw offset of Position
+	Time     float32
+}
+
+const (
+	SCHEDULER_PAUSE = iota
+	SCHEDULER_RESUME
+	SCHEDULER_STEP
+	SCHEDULER_SEEK
+	SCHEDULER_SPEED
+	SCHEDULER_STOP
+)
+
+type control struct {
+	kind  int
+	value float32
+}
+
+// Scheduler plays a Player in its own goroutine, sending the chunks on Chunks when they are due.
+// Chunks is closed at the end of the recording or when the Scheduler is stopped, the Player can
+// only be used again after that.
+type Scheduler struct {
+	Chunks  chan Chunk
+	player  *Player
+	control chan control
+	done    chan bool
+	err     error
+}
+
+// NewScheduler starts playing player from where it is.
+func NewScheduler(player *Player) *Scheduler {
+	scheduler := &Scheduler{
+		Chunks:  make(chan Chunk),
+		player:  player,
+		control: make(chan control),
+		done:    make(chan bool),
+	}
+	go scheduler.run()
+	return scheduler
+}
+
+func (s *Scheduler) send(c control) {
+	select {
+	case s.control <- c:
+	case <-s.done: // it is already over
+	}
+}
+
+func (s *Scheduler) Pause() {
+	s.send(control{SCHEDULER_PAUSE, 0})
+}
+
+func (s *Scheduler) Resume() {
+	s.send(control{SCHEDULER_RESUME, 0})
+}
+
+// Step sends the next chunk right now, even when paused.
+func (s *Scheduler) Step() {
+	s.send(control{SCHEDULER_STEP, 0})
+}
+
+// Seek jumps to the time at, the next chunk redraws the screen.
+func (s *Scheduler) Seek(at float32) {
+	s.send(control{SCHEDULER_SEEK, at})
+}
+
+func (s *Scheduler) SetSpeed(speed float32) {
+	s.send(control{SCHEDULER_SPEED, speed})
+}
+
+// Stop ends the replay, the chunks that have not been received yet are dropped.
+func (s *Scheduler) Stop() {
+	s.send(control{SCHEDULER_STOP, 0})
+	for _ = range s.Chunks {
+	}
+}
+
+// Err returns why the replay failed once Chunks is closed, nil when it ended normally.
+func (s *Scheduler) Err() error {
+	return s.err
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	defer close(s.Chunks)
+	defer func() {
+		// a damaged recording must not take the whole program, and its terminal, down
+		if r := recover(); r != nil {
+			s.err = fmt.Errorf("the replay failed: %v", r)
+		}
+	}()
+
+	var pending Chunk
+	var deadline time.Time
+	var remaining time.Duration // left to wait when paused
+	next := func() bool {
+		delay, data, ok := s.player.Next()
+		if !ok {
+			return false
+		}
+		pending = Chunk{data, s.player.Position(), s.player.Bytepos(), s.player.Time()}
+		deadline = time.Now().Add(delay)
+		remaining = delay
+		return true
+	}
+	if !next() {
+		return
+	}
+	paused, ready := false, false
+	for {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		var output chan Chunk
+		if ready {
+			output = s.Chunks
+		} else if !paused {
+			timer = time.NewTimer(deadline.Sub(time.Now()))
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+			ready = true
+		case output <- pending:
+			ready = false
+			if !next() {
+				return
+			}
+			if paused {
+				remaining = deadline.Sub(time.Now())
+			}
+		case c := <-s.control:
+			switch c.kind {
+			case SCHEDULER_PAUSE:
+				if !paused {
+					remaining = deadline.Sub(time.Now())
+				}
+				paused = true
+			case SCHEDULER_RESUME:
+				if paused {
+					deadline = time.Now().Add(remaining)
+				}
+				paused = false
+			case SCHEDULER_STEP:
+				ready = true
+			case SCHEDULER_SEEK:
+				data := s.player.Seek(c.value)
+				pending = Chunk{data, s.player.Position(), s.player.Bytepos(), s.player.Time()}
+				ready = true
+			case SCHEDULER_SPEED:
+				ratio := float64(s.player.Speed) / float64(c.value)
+				s.player.Speed = c.value
+				if paused {
+					remaining = time.Duration(float64(remaining) * ratio)
+				} else {
+					deadline = time.Now().Add(time.Duration(float64(deadline.Sub(time.Now())) * ratio))
+				}
+			case SCHEDULER_STOP:
+				return
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package scriptedit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerPlaysToTheEnd(t *testing.T) {
+	state := recording(t, "abcdef", []Timing{Timing{0.01, 2}, Timing{0.02, 3}, Timing{0.01, 1}})
+	started := time.Now()
+	scheduler := NewScheduler(NewPlayer(state))
+	var played string
+	var last Chunk
+	for chunk := range scheduler.Chunks {
+		played += string(chunk.Data)
+		last = chunk
+	}
+	if played != "abcdef" || last.Position != 6 || last.Bytepos != 6 || last.Time != 0.04 {
+		t.Errorf("Wrong replay %q %+v", played, last)
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("The delays were not respected, it took %s", elapsed)
+	}
+	scheduler.Stop() // harmless once it is over
+}
+
+func TestSchedulerControls(t *testing.T) {
+	state := recording(t, "ab\033[31mcd", []Timing{Timing{10, 3}, Timing{10, 4}, Timing{10, 2}})
+	player := NewPlayer(state)
+	player.SeekPosition(1)
+	scheduler := NewScheduler(player)
+	if chunk := <-scheduler.Chunks; string(chunk.Data) != "b\033" || chunk.Position != 2 {
+		t.Errorf("The rest of the current chunk should be played at once, got %+v", chunk)
+	}
+	scheduler.Pause()
+	scheduler.SetSpeed(1000)
+	scheduler.Step()
+	if chunk := <-scheduler.Chunks; string(chunk.Data) != "[31m" || chunk.Bytepos != 7 || chunk.Time != 20 {
+		t.Errorf("Wrong step %+v", chunk)
+	}
+	scheduler.Seek(0)
+	if chunk := <-scheduler.Chunks; chunk.Position != 0 || chunk.Time != 0 {
+		t.Errorf("Wrong seek %+v", chunk)
+	}
+	scheduler.Resume()
+	if chunk := <-scheduler.Chunks; string(chunk.Data) != "ab\033" || chunk.Position != 2 {
+		t.Errorf("The replay should go on at the new speed %+v", chunk)
+	}
+	scheduler.Stop()
+	if _, ok := <-scheduler.Chunks; ok {
+		t.Errorf("Chunks should be closed")
+	}
+}
+
+func TestSchedulerReportsAFailure(t *testing.T) {
+	player := NewPlayer(recording(t, "abc", []Timing{Timing{0, 3}}))
+	player.state = nil // broken
+	scheduler := NewScheduler(player)
+	for _ = range scheduler.Chunks {
+	}
+	if scheduler.Err() == nil {
+		t.Errorf("The failure of the replay should be reported")
+	}
+}
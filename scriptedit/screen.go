@@ -0,0 +1,761 @@
+package scriptedit
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Cell struct {
+	Ch   rune
+	Attr Attr
+	Tail bool // right half of a double width character
+}
+
+type savedCursor struct {
+	x, y     int
+	attr     Attr
+	origin   bool
+	charsets [2]bool
+	charset  int
+}
+
+// Screen is a headless VT100/xterm model: it applies AnsiCmds to a grid of cells
+// so the editor can know what is on screen without asking a real terminal.
+type Screen struct {
+	Width  int
+	Height int
+	Cells  [][]Cell // Cells[row][column]
+	X      int      // cursor column, 0 based
+	Y      int      // cursor row, 0 based
+	Attr   Attr     // current pen
+	Top    int      // scrolling region, 0 based and inclusive
+	Bottom int
+
+	CursorVisible bool
+	AutoWrap      bool
+	Origin        bool
+	Insert        bool
+	AltScreen     bool // the alternate screen buffer is displayed
+
+	altCells    [][]Cell // the buffer that is not displayed
+	lastChar    rune
+	wrapPending bool
+	tabs        []bool
+	saved       savedCursor
+	charsets    [2]bool // true when G0/G1 is mapped to the DEC graphics set
+	charset     int     // active charset (SO/SI)
+}
+
+// the largest screen, a recording asking for more is clipped
+const MAX_WIDTH = 1024
+const MAX_HEIGHT = 512
+
+func clampSize(width, height int) (int, int) {
+	if width < 1 {
+		width = 1
+	} else if width > MAX_WIDTH {
+		width = MAX_WIDTH
+	}
+	if height < 1 {
+		height = 1
+	} else if height > MAX_HEIGHT {
+		height = MAX_HEIGHT
+	}
+	return width, height
+}
+
+func NewScreen(width, height int) *Screen {
+	width, height = clampSize(width, height)
+	screen := &Screen{Width: width, Height: height}
+	screen.Reset()
+	return screen
+}
+
+// Reset puts the screen back in its power-on state (RIS)
+func (s *Screen) Reset() {
+	s.Attr = DEFAULT_ATTR
+	s.Cells = make([][]Cell, s.Height)
+	for y := range s.Cells {
+		s.Cells[y] = s.blankLine()
+	}
+	s.X, s.Y = 0, 0
+	s.Top, s.Bottom = 0, s.Height-1
+	s.CursorVisible = true
+	s.AutoWrap = true
+	s.Origin = false
+	s.Insert = false
+	s.AltScreen = false
+	s.altCells = nil
+	s.lastChar = ' '
+	s.wrapPending = false
+	s.tabs = make([]bool, s.Width)
+	for x := 0; x < s.Width; x += 8 {
+		s.tabs[x] = true
+	}
+	s.charsets = [2]bool{}
+	s.charset = 0
+	s.saved = savedCursor{attr: DEFAULT_ATTR}
+}
+
+func (s *Screen) Clone() *Screen {
+	clone := *s
+	clone.Cells = make([][]Cell, len(s.Cells))
+	for y, line := range s.Cells {
+		clone.Cells[y] = append([]Cell(nil), line...)
+	}
+	if s.altCells != nil {
+		clone.altCells = make([][]Cell, len(s.altCells))
+		for y, line := range s.altCells {
+			clone.altCells[y] = append([]Cell(nil), line...)
+		}
+	}
+	clone.tabs = append([]bool(nil), s.tabs...)
+	return &clone
+}
+
+// Resize changes the size of the screen keeping the top left part of its content
+func (s *Screen) Resize(width, height int) {
+	width, height = clampSize(width, height)
+	resize := func(cells [][]Cell) [][]Cell {
+		resized := make([][]Cell, height)
+		for y := range resized {
+			resized[y] = make([]Cell, width)
+			for x := range resized[y] {
+				resized[y][x] = Cell{Ch: ' ', Attr: DEFAULT_ATTR}
+			}
+			if y < len(cells) {
+				copy(resized[y], cells[y])
+			}
+		}
+		return resized
+	}
+	s.Cells = resize(s.Cells)
+	if s.altCells != nil {
+		s.altCells = resize(s.altCells)
+	}
+	tabs := make([]bool, width)
+	copy(tabs, s.tabs)
+	for x := len(s.tabs) - len(s.tabs)%8; x < width; x += 8 {
+		tabs[x] = true
+	}
+	s.tabs = tabs
+	s.Width, s.Height = width, height
+	s.Top, s.Bottom = 0, height-1
+	s.X, s.Y = s.clampX(s.X), s.clampY(s.Y)
+	s.wrapPending = false
+}
+
+// Cursor returns the cursor position like a terminal reports it: 1 based row and column.
+func (s *Screen) Cursor() (int, int) {
+	return s.Y + 1, s.X + 1
+}
+
+// Line returns the text of a row with the trailing blanks removed.
+func (s *Screen) Line(y int) string {
+	var line []rune
+	for _, cell := range s.Cells[y] {
+		if cell.Tail {
+			continue
+		}
+		if cell.Ch == 0 {
+			line = append(line, ' ')
+		} else {
+			line = append(line, cell.Ch)
+		}
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+func (s *Screen) String() string {
+	lines := make([]string, s.Height)
+	for y := range lines {
+		lines[y] = s.Line(y)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Screen) Feed(cmds []AnsiCmd) {
+	for _, cmd := range cmds {
+		s.Apply(cmd)
+	}
+}
+
+func (s *Screen) Apply(cmd AnsiCmd) {
+	if cmd.Code == nil {
+		s.applyLetter(cmd.Letter)
+		return
+	}
+	switch cmd.Code.Prefix {
+	case CSI_CHR:
+		s.applyCSI(cmd.Code.Code, cmd.Params)
+	case '(':
+		s.charsets[0] = cmd.Code.Code == '0'
+	case ')':
+		s.charsets[1] = cmd.Code.Code == '0'
+	case RIS.Prefix:
+		s.Reset()
+	case IND.Prefix:
+		s.index()
+	case NEL.Prefix:
+		s.X = 0
+		s.index()
+	case HTS.Prefix:
+		s.tabs[s.X] = true
+	case RI.Prefix:
+		s.reverseIndex()
+	case DECSC.Prefix:
+		s.saveCursor()
+	case DECRC.Prefix:
+		s.restoreCursor()
+	}
+}
+
+var decGraphics = map[rune]rune{
+	'`': '◆', 'a': '▒', 'b': '␉', 'c': '␌', 'd': '␍', 'e': '␊', 'f': '°', 'g': '±',
+	'h': '␤', 'i': '␋', 'j': '┘', 'k': '┐', 'l': '┌', 'm': '└', 'n': '┼', 'o': '⎺',
+	'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽', 't': '├', 'u': '┤', 'v': '┴', 'w': '┬',
+	'x': '│', 'y': '≤', 'z': '≥', '{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+func (s *Screen) applyLetter(r rune) {
+	switch r {
+	case '\b':
+		s.wrapPending = false
+		if s.X > 0 {
+			s.X--
+		}
+	case '\t':
+		s.wrapPending = false
+		s.tab(1)
+	case '\n', '\v', '\f':
+		s.wrapPending = false
+		s.index()
+	case '\r':
+		s.wrapPending = false
+		s.X = 0
+	case '\016': // SO
+		s.charset = 1
+	case '\017': // SI
+		s.charset = 0
+	default:
+		if r < 0x20 || r == 0x7f {
+			return // other control characters do not print
+		}
+		if s.charsets[s.charset] {
+			if mapped, ok := decGraphics[r]; ok {
+				r = mapped
+			}
+		}
+		s.lastChar = r
+		s.print(r)
+	}
+}
+
+func (s *Screen) print(r rune) {
+	width := RuneWidth(r)
+	if s.Width < 2 {
+		width = 1 // no room for the second half
+	}
+	if s.wrapPending {
+		s.wrapPending = false
+		if s.AutoWrap {
+			s.X = 0
+			s.index()
+		}
+	}
+	if width == 2 && s.X == s.Width-1 {
+		if !s.AutoWrap {
+			return
+		}
+		s.Cells[s.Y][s.X] = s.blankCell()
+		s.X = 0
+		s.index()
+	}
+	if s.Insert {
+		s.insertChars(width)
+	}
+	line := s.Cells[s.Y]
+	s.clearWide(s.Y, s.X)
+	line[s.X] = Cell{Ch: r, Attr: s.Attr}
+	if width == 2 {
+		s.clearWide(s.Y, s.X+1)
+		line[s.X+1] = Cell{Attr: s.Attr, Tail: true}
+	}
+	s.X += width
+	if s.X >= s.Width {
+		s.X = s.Width - 1
+		s.wrapPending = true
+	}
+}
+
+// clearWide blanks the other half of a double width character about to be overwritten
+func (s *Screen) clearWide(y, x int) {
+	line := s.Cells[y]
+	if line[x].Tail && x > 0 {
+		line[x-1] = s.blankCell()
+	} else if x+1 < s.Width && line[x+1].Tail {
+		line[x+1] = s.blankCell()
+	}
+}
+
+func (s *Screen) blankCell() Cell {
+	attr := DEFAULT_ATTR
+	attr.Bg = s.Attr.Bg
+	return Cell{Ch: ' ', Attr: attr}
+}
+
+func (s *Screen) blankLine() []Cell {
+	line := make([]Cell, s.Width)
+	blank := s.blankCell()
+	for x := range line {
+		line[x] = blank
+	}
+	return line
+}
+
+func (s *Screen) index() {
+	if s.Y == s.Bottom {
+		s.scrollUp(s.Top, 1)
+	} else if s.Y < s.Height-1 {
+		s.Y++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	if s.Y == s.Top {
+		s.scrollDown(s.Top, 1)
+	} else if s.Y > 0 {
+		s.Y--
+	}
+}
+
+// scrollUp moves the lines from top to the bottom margin n lines up
+func (s *Screen) scrollUp(top, n int) {
+	if n > s.Bottom-top+1 {
+		n = s.Bottom - top + 1
+	}
+	copy(s.Cells[top:s.Bottom+1], s.Cells[top+n:s.Bottom+1])
+	for y := s.Bottom - n + 1; y <= s.Bottom; y++ {
+		s.Cells[y] = s.blankLine()
+	}
+}
+
+// scrollDown moves the lines from top to the bottom margin n lines down
+func (s *Screen) scrollDown(top, n int) {
+	if n > s.Bottom-top+1 {
+		n = s.Bottom - top + 1
+	}
+	copy(s.Cells[top+n:s.Bottom+1], s.Cells[top:s.Bottom+1-n])
+	for y := top; y < top+n; y++ {
+		s.Cells[y] = s.blankLine()
+	}
+}
+
+func (s *Screen) tab(n int) {
+	for ; n > 0; n-- {
+		for s.X < s.Width-1 {
+			s.X++
+			if s.tabs[s.X] {
+				break
+			}
+		}
+	}
+}
+
+func (s *Screen) saveCursor() {
+	s.saved = savedCursor{s.X, s.Y, s.Attr, s.Origin, s.charsets, s.charset}
+}
+
+func (s *Screen) restoreCursor() {
+	s.X, s.Y = s.clampX(s.saved.x), s.clampY(s.saved.y)
+	s.Attr = s.saved.attr
+	s.Origin = s.saved.origin
+	s.charsets = s.saved.charsets
+	s.charset = s.saved.charset
+	s.wrapPending = false
+}
+
+func (s *Screen) clampX(x int) int {
+	if x < 0 {
+		return 0
+	}
+	if x >= s.Width {
+		return s.Width - 1
+	}
+	return x
+}
+
+func (s *Screen) clampY(y int) int {
+	if y < 0 {
+		return 0
+	}
+	if y >= s.Height {
+		return s.Height - 1
+	}
+	return y
+}
+
+// moveTo positions the cursor, honouring the origin mode
+func (s *Screen) moveTo(y, x int) {
+	if s.Origin {
+		y += s.Top
+		if y > s.Bottom {
+			y = s.Bottom
+		}
+	}
+	s.Y = s.clampY(y)
+	s.X = s.clampX(x)
+	s.wrapPending = false
+}
+
+// moveVertically moves the cursor n lines without leaving the scrolling region it is in
+func (s *Screen) moveVertically(n int) {
+	top, bottom := 0, s.Height-1
+	if s.Y >= s.Top && s.Y <= s.Bottom {
+		top, bottom = s.Top, s.Bottom
+	}
+	y := s.Y + n
+	if y < top {
+		y = top
+	}
+	if y > bottom {
+		y = bottom
+	}
+	s.Y = y
+	s.wrapPending = false
+}
+
+const MAX_PARAM = 9999 // larger parameters are clamped, like the negative ones to 0
+
+// splitParams cuts CSI parameters like "?1;2" into its private marker and numbers
+func splitParams(params string) (string, []int) {
+	var private string
+	for len(params) > 0 && params[0] >= '<' && params[0] <= '?' {
+		private += params[:1]
+		params = params[1:]
+	}
+	if params == "" {
+		return private, nil
+	}
+	fields := strings.Split(params, ";")
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		if colon := strings.IndexByte(field, ':'); colon >= 0 {
+			field = field[:colon]
+		}
+		number, err := strconv.Atoi(field)
+		if number > MAX_PARAM || (err != nil && strings.Trim(field, "0123456789") == "") {
+			number = MAX_PARAM // too large for an int
+		} else if number < 0 {
+			number = 0
+		}
+		numbers[i] = number
+	}
+	return private, numbers
+}
+
+// param returns the nth parameter or def when it is missing or 0
+func param(params []int, n, def int) int {
+	if n < len(params) && params[n] != 0 {
+		return params[n]
+	}
+	return def
+}
+
+func (s *Screen) applyCSI(final rune, raw string) {
+	private, params := splitParams(raw)
+	n := param(params, 0, 1)
+	switch final {
+	case ICH.Code:
+		s.insertChars(n)
+	case CUU.Code:
+		s.moveVertically(-n)
+	case CUD.Code, VPR.Code:
+		s.moveVertically(n)
+	case CUF.Code, HPR.Code:
+		s.X = s.clampX(s.X + n)
+		s.wrapPending = false
+	case CUB.Code:
+		s.X = s.clampX(s.X - n)
+		s.wrapPending = false
+	case CNL.Code:
+		s.moveVertically(n)
+		s.X = 0
+	case CPL.Code:
+		s.moveVertically(-n)
+		s.X = 0
+	case CHA.Code, HPA.Code:
+		s.X = s.clampX(n - 1)
+		s.wrapPending = false
+	case CUP.Code, HVP.Code:
+		s.moveTo(param(params, 0, 1)-1, param(params, 1, 1)-1)
+	case VPA.Code:
+		s.moveTo(n-1, s.X)
+	case ED.Code:
+		s.eraseDisplay(param(params, 0, 0))
+	case EL.Code:
+		s.eraseLine(s.Y, param(params, 0, 0))
+	case IL.Code:
+		if s.Y >= s.Top && s.Y <= s.Bottom {
+			s.scrollDown(s.Y, n)
+			s.X = 0
+		}
+	case DL.Code:
+		if s.Y >= s.Top && s.Y <= s.Bottom {
+			s.scrollUp(s.Y, n)
+			s.X = 0
+		}
+	case DCH.Code:
+		s.deleteChars(n)
+	case ECH.Code:
+		s.erase(s.Y, s.X, s.X+n)
+	case SGR.Code:
+		if private == "" {
+			s.Attr.Apply(DecodeSGR(raw))
+		}
+	case DECSTBM.Code:
+		top, bottom := param(params, 0, 1)-1, param(params, 1, s.Height)-1
+		if top >= s.Height {
+			top = s.Height - 1
+		}
+		if bottom >= s.Height {
+			bottom = s.Height - 1
+		}
+		if top < bottom {
+			s.Top, s.Bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case CUPSV.Code:
+		s.saveCursor()
+	case CUPRS.Code:
+		s.restoreCursor()
+	case CHT.Code:
+		s.tab(n)
+	case CBT.Code:
+		for ; n > 0 && s.X > 0; n-- {
+			s.X--
+			for s.X > 0 && !s.tabs[s.X] {
+				s.X--
+			}
+		}
+		s.wrapPending = false
+	case SU.Code:
+		s.scrollUp(s.Top, n)
+	case SD.Code:
+		s.scrollDown(s.Top, n)
+	case REP.Code:
+		if n > s.Width*s.Height {
+			n = s.Width * s.Height // more only prints over the same cells
+		}
+		for ; n > 0; n-- {
+			s.print(s.lastChar)
+		}
+	case SM.Code, RM.Code:
+		for _, mode := range params {
+			s.setMode(private, mode, final == SM.Code)
+		}
+	case WINOPS.Code:
+		if param(params, 0, 0) == 8 {
+			s.Resize(param(params, 2, s.Width), param(params, 1, s.Height))
+		}
+	case TBC.Code:
+		switch param(params, 0, 0) {
+		case 0:
+			s.tabs[s.X] = false
+		case 3:
+			s.tabs = make([]bool, s.Width)
+		}
+	}
+}
+
+func (s *Screen) setMode(private string, mode int, set bool) {
+	if private == "" {
+		if mode == 4 {
+			s.Insert = set
+		}
+		return
+	}
+	if private != "?" {
+		return
+	}
+	switch mode {
+	case 6:
+		s.Origin = set
+		s.moveTo(0, 0)
+	case 7:
+		s.AutoWrap = set
+	case 25:
+		s.CursorVisible = set
+	case 47:
+		s.switchBuffer(set, false)
+	case 1047:
+		if !set && s.AltScreen {
+			s.eraseDisplay(2)
+		}
+		s.switchBuffer(set, false)
+	case 1048:
+		if set {
+			s.saveCursor()
+		} else {
+			s.restoreCursor()
+		}
+	case 1049:
+		if set {
+			s.saveCursor()
+			s.switchBuffer(true, true)
+		} else {
+			s.switchBuffer(false, false)
+			s.restoreCursor()
+		}
+	}
+}
+
+// switchBuffer displays the alternate screen buffer (or the normal one)
+func (s *Screen) switchBuffer(alt bool, clear bool) {
+	if alt == s.AltScreen {
+		return
+	}
+	if s.altCells == nil {
+		s.altCells = make([][]Cell, s.Height)
+		for y := range s.altCells {
+			s.altCells[y] = s.blankLine()
+		}
+	}
+	s.Cells, s.altCells = s.altCells, s.Cells
+	s.AltScreen = alt
+	if clear {
+		s.eraseDisplay(2)
+	}
+}
+
+func (s *Screen) erase(y, from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > s.Width {
+		to = s.Width
+	}
+	blank := s.blankCell()
+	for x := from; x < to; x++ {
+		s.Cells[y][x] = blank
+	}
+}
+
+func (s *Screen) eraseLine(y, mode int) {
+	switch mode {
+	case 0:
+		s.erase(y, s.X, s.Width)
+	case 1:
+		s.erase(y, 0, s.X+1)
+	case 2:
+		s.erase(y, 0, s.Width)
+	}
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(s.Y, 0)
+		for y := s.Y + 1; y < s.Height; y++ {
+			s.erase(y, 0, s.Width)
+		}
+	case 1:
+		for y := 0; y < s.Y; y++ {
+			s.erase(y, 0, s.Width)
+		}
+		s.eraseLine(s.Y, 1)
+	case 2, 3:
+		for y := 0; y < s.Height; y++ {
+			s.erase(y, 0, s.Width)
+		}
+	}
+}
+
+func (s *Screen) insertChars(n int) {
+	line := s.Cells[s.Y]
+	if n > s.Width-s.X {
+		n = s.Width - s.X
+	}
+	copy(line[s.X+n:], line[s.X:])
+	s.erase(s.Y, s.X, s.X+n)
+	s.wrapPending = false
+}
+
+func (s *Screen) deleteChars(n int) {
+	line := s.Cells[s.Y]
+	if n > s.Width-s.X {
+		n = s.Width - s.X
+	}
+	copy(line[s.X:], line[s.X+n:])
+	s.erase(s.Y, s.Width-n, s.Width)
+	s.wrapPending = false
+}
+
+// Render serialises the screen into a stream that recreates it on a real terminal:
+// content, scrolling region, saved cursor, cursor position and pen.
+func (s *Screen) Render() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(RESET_COLOR + ESC + "[?7l" + ESC + "[r")
+	if s.AltScreen {
+		paintCells(&buffer, s.altCells)
+		buffer.WriteString(RESET_COLOR + ESC + "[?1047h")
+	}
+	paintCells(&buffer, s.Cells)
+	buffer.WriteString(ESC + "[3g")
+	for x, tab := range s.tabs {
+		if tab {
+			buffer.WriteString(fmt.Sprintf(MOVE_CURSOR, 1, x+1) + ESC + "H")
+		}
+	}
+	if s.AutoWrap {
+		buffer.WriteString(ESC + "[?7h")
+	}
+	buffer.WriteString(fmt.Sprintf(ESC+"[%d;%dr", s.Top+1, s.Bottom+1))
+	buffer.WriteString(fmt.Sprintf(MOVE_CURSOR, s.saved.y+1, s.saved.x+1))
+	buffer.WriteString(s.saved.attr.SGRString() + ESC + "7")
+	if !s.CursorVisible {
+		buffer.WriteString(ESC + "[?25l")
+	}
+	if s.Origin {
+		buffer.WriteString(ESC + "[?6h" + fmt.Sprintf(MOVE_CURSOR, s.Y-s.Top+1, s.X+1))
+	} else {
+		buffer.WriteString(fmt.Sprintf(MOVE_CURSOR, s.Y+1, s.X+1))
+	}
+	buffer.WriteString(s.Attr.SGRString())
+	if s.charsets[0] {
+		buffer.WriteString(ESC + "(0")
+	}
+	if s.charsets[1] {
+		buffer.WriteString(ESC + ")0")
+	}
+	if s.charset == 1 {
+		buffer.WriteString("\016")
+	}
+	if s.Insert {
+		buffer.WriteString(ESC + "[4h")
+	}
+	return buffer.String()
+}
+
+func paintCells(buffer *bytes.Buffer, cells [][]Cell) {
+	attr := DEFAULT_ATTR
+	for y, line := range cells {
+		buffer.WriteString(fmt.Sprintf(MOVE_CURSOR, y+1, 1))
+		for _, cell := range line {
+			if cell.Tail {
+				continue
+			}
+			if cell.Attr != attr {
+				attr = cell.Attr
+				buffer.WriteString(attr.SGRString())
+			}
+			if cell.Ch == 0 {
+				buffer.WriteRune(' ')
+			} else {
+				buffer.WriteRune(cell.Ch)
+			}
+		}
+	}
+}
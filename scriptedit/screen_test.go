@@ -0,0 +1,153 @@
+package scriptedit
+
+import (
+	"testing"
+)
+
+func playOnScreen(width, height int, doc string) *Screen {
+	screen := NewScreen(width, height)
+	screen.Feed(parse(doc))
+	return screen
+}
+
+func TestScreenPrint(t *testing.T) {
+	screen := playOnScreen(10, 3, "hello\r\nworld")
+	if screen.Line(0) != "hello" || screen.Line(1) != "world" {
+		t.Errorf("Wrong content\n%s", screen)
+	}
+	row, col := screen.Cursor()
+	if row != 2 || col != 6 {
+		t.Errorf("Wrong cursor %dx%d", row, col)
+	}
+}
+
+func TestScreenWrapAndScroll(t *testing.T) {
+	screen := playOnScreen(4, 2, "abcdefgh\r\nij")
+	if screen.Line(0) != "efgh" || screen.Line(1) != "ij" {
+		t.Errorf("Wrong content\n%s", screen)
+	}
+}
+
+func TestScreenCursorMovements(t *testing.T) {
+	screen := playOnScreen(20, 5, "\033[3;4Hx\033[2Ay\033[10Dz\033[Bw")
+	if screen.Line(0) != "z   y" || screen.Line(2) != "   x" {
+		t.Errorf("Wrong content\n%s", screen)
+	}
+	if screen.Line(1) != " w" {
+		t.Errorf("Wrong content\n%s", screen)
+	}
+}
+
+func TestScreenErase(t *testing.T) {
+	screen := playOnScreen(10, 2, "0123456789\033[1;5H\033[K\033[2;1Habc\033[1;2H\033[1P")
+	if screen.Line(0) != "023" {
+		t.Errorf("Wrong content %q", screen.Line(0))
+	}
+	screen.Feed(parse("\033[2J"))
+	if screen.String() != "\n" {
+		t.Errorf("Screen not erased\n%s", screen)
+	}
+}
+
+func TestScreenScrollRegion(t *testing.T) {
+	screen := playOnScreen(5, 4, "head\033[2;3r\033[2;1Ha\r\nb\r\nc\033[4;1Hfoot")
+	if screen.Line(0) != "head" || screen.Line(1) != "b" || screen.Line(2) != "c" || screen.Line(3) != "foot" {
+		t.Errorf("Wrong scrolling\n%s", screen)
+	}
+}
+
+func TestScreenSaveRestoreCursor(t *testing.T) {
+	screen := playOnScreen(10, 3, "ab\033[s\033[3;8Hx\033[uc")
+	if screen.Line(0) != "abc" {
+		t.Errorf("Wrong content %q", screen.Line(0))
+	}
+}
+
+func TestScreenTabs(t *testing.T) {
+	screen := playOnScreen(20, 1, "a\tb")
+	if screen.Line(0) != "a       b" {
+		t.Errorf("Wrong tabulation %q", screen.Line(0))
+	}
+}
+
+func TestScreenSGR(t *testing.T) {
+	screen := playOnScreen(10, 1, "\033[1;31ma\033[38;5;200;48;2;1;2;3mb\033[0mc")
+	a, b, c := screen.Cells[0][0].Attr, screen.Cells[0][1].Attr, screen.Cells[0][2].Attr
+	if !a.Bold || a.Fg != IndexedColor(1) {
+		t.Errorf("Wrong attributes %+v", a)
+	}
+	if b.Fg != IndexedColor(200) || b.Bg != RGBColor(1, 2, 3) {
+		t.Errorf("Wrong attributes %+v", b)
+	}
+	if c != DEFAULT_ATTR {
+		t.Errorf("Wrong attributes %+v", c)
+	}
+}
+
+func TestNextSameCursorPosition(t *testing.T) {
+	state := NewEditorState()
+	doc := "% cd mydirrec\b\b\b   \b\b\bectory"
+	state.Content = parse(doc)
+	state.Timings = []Timing{Timing{1, len(doc)}}
+	state.Position = 10 // just before "rec"
+	if !state.NextSameCursorPosition() {
+		t.Fatal("Did not find the same cursor position")
+	}
+	if state.Position != 16 {
+		t.Errorf("Wrong position %d", state.Position)
+	}
+}
+
+func TestScreenRender(t *testing.T) {
+	screen := playOnScreen(10, 4, "\033[1;33mab\033[0mc\033[2;3r\033[3;4Hx\0337\033[4;1H界\033[44m")
+	copy := playOnScreen(10, 4, screen.Render())
+	for y := range screen.Cells {
+		for x := range screen.Cells[y] {
+			if screen.Cells[y][x] != copy.Cells[y][x] {
+				t.Errorf("Wrong cell at %dx%d %+v != %+v", y, x, copy.Cells[y][x], screen.Cells[y][x])
+			}
+		}
+	}
+	if copy.X != screen.X || copy.Y != screen.Y || copy.Attr != screen.Attr {
+		t.Errorf("Wrong cursor %dx%d %+v", copy.Y, copy.X, copy.Attr)
+	}
+	if copy.Top != 1 || copy.Bottom != 2 || copy.saved != screen.saved {
+		t.Errorf("Wrong region or saved cursor %d-%d %+v", copy.Top, copy.Bottom, copy.saved)
+	}
+}
+
+func TestScreenModes(t *testing.T) {
+	screen := playOnScreen(10, 3, "main\033[?1049h\033[?25lalt")
+	if screen.Line(0) != "    alt" || screen.CursorVisible || !screen.AltScreen {
+		t.Errorf("Wrong alternate screen\n%s", screen)
+	}
+	screen.Feed(parse("\033[?1049l\033[4hX"))
+	if screen.Line(0) != "mainX" {
+		t.Errorf("Wrong normal screen\n%s", screen)
+	}
+	screen.Feed(parse("\r\033[8;2;5t"))
+	if screen.Width != 5 || screen.Height != 2 || screen.Line(0) != "mainX" {
+		t.Errorf("Wrong resize %dx%d\n%s", screen.Width, screen.Height, screen)
+	}
+}
+
+func TestScreenInvalidParameters(t *testing.T) {
+	for _, doc := range []string{"\033[-5;1r\n\n\n\n", "abc\033[-5@", "abc\033[-9P", "\033[-3S", "\033[-3T", "\033[-3L", "\033[-3M",
+		"\033[99999999999999999999C\033[5;-9999r\n\n\n\n\033[-1;-1Hx", "\033[8;1;1t世"} {
+		screen := playOnScreen(10, 4, doc) // it should not panic
+		screen.Render()
+	}
+	if screen := playOnScreen(10, 4, "abc\r\033[-5@"); screen.Line(0) != " abc" {
+		t.Errorf("A negative parameter should be the default\n%s", screen)
+	}
+}
+
+func TestScreenSizeLimits(t *testing.T) {
+	screen := playOnScreen(10, 4, "\033[8;99999;99999ta\033[999999999b")
+	if screen.Width != MAX_WIDTH || screen.Height != MAX_HEIGHT {
+		t.Errorf("The size should be capped, got %dx%d", screen.Width, screen.Height)
+	}
+	if screen = NewScreen(1<<20, 0); screen.Width != MAX_WIDTH || screen.Height != 1 {
+		t.Errorf("The size should be capped, got %dx%d", screen.Width, screen.Height)
+	}
+}
@@ -0,0 +1,27 @@
+package scriptedit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ScriptHeader is the first line util-linux script writes in the session.
+func ScriptHeader(started time.Time, term string, width, height int) string {
+	return fmt.Sprintf("Script started on %s [TERM=\"%s\" COLUMNS=\"%d\" LINES=\"%d\"]\n",
+		started.Format("2006-01-02 15:04:05-07:00"), term, width, height)
+}
+
+var scriptSize = regexp.MustCompile(`COLUMNS="(\d+)" LINES="(\d+)"`)
+
+// ParseScriptHeader returns the size of the terminal recorded in a session header, 0 if it is not there.
+func ParseScriptHeader(header string) (int, int) {
+	match := scriptSize.FindStringSubmatch(header)
+	if match == nil {
+		return 0, 0
+	}
+	width, _ := strconv.Atoi(match[1])
+	height, _ := strconv.Atoi(match[2])
+	return width, height
+}
@@ -0,0 +1,72 @@
+package scriptedit
+
+// Every KEYFRAME_INTERVAL entries of the Content, a snapshot of the screen is kept
+// so seeking only needs to replay the tail from the nearest one.
+const KEYFRAME_INTERVAL = 16384
+
+type keyframe struct {
+	position int // keyframes[k] is at position k * KEYFRAME_INTERVAL
+	bytepos  int
+	screen   *Screen
+}
+
+// ScreenAt returns the headless screen as it is after playing Content[0:position].
+// The returned screen is shared, clone it before modifying it.
+func (state *EditorState) ScreenAt(position int) *Screen {
+	frame := state.keyframeBefore(position)
+	if state.screen == nil || state.screenPosition > position || state.screenPosition < frame.position {
+		state.screen = frame.screen.Clone()
+		state.screenPosition = frame.position
+	}
+	state.feedScreen(position)
+	return state.screen
+}
+
+// KeyframeBefore returns the position, byte offset and screen of the closest snapshot at or before position.
+// The returned screen is shared, clone it before modifying it.
+func (state *EditorState) KeyframeBefore(position int) (int, int, *Screen) {
+	frame := state.keyframeBefore(position)
+	return frame.position, frame.bytepos, frame.screen
+}
+
+func (state *EditorState) keyframeBefore(position int) keyframe {
+	if len(state.keyframes) == 0 {
+		state.keyframes = []keyframe{keyframe{0, 0, NewScreen(state.TerminalSize())}}
+	}
+	k := position / KEYFRAME_INTERVAL
+	if k < len(state.keyframes) {
+		return state.keyframes[k]
+	}
+	// extend the index up to position
+	last := state.keyframes[len(state.keyframes)-1]
+	state.screen = last.screen.Clone()
+	state.screenPosition = last.position
+	state.feedScreen(k * KEYFRAME_INTERVAL)
+	return state.keyframes[len(state.keyframes)-1]
+}
+
+// feedScreen plays the content on the cached screen up to position, recording the keyframes it crosses
+func (state *EditorState) feedScreen(position int) {
+	for state.screenPosition < position {
+		state.screen.Apply(state.Content[state.screenPosition])
+		state.screenPosition++
+		if state.screenPosition%KEYFRAME_INTERVAL == 0 && state.screenPosition/KEYFRAME_INTERVAL == len(state.keyframes) {
+			previous := state.keyframes[len(state.keyframes)-1]
+			bytepos := previous.bytepos
+			for _, ansi := range state.Content[previous.position:state.screenPosition] {
+				bytepos += len(ansi.String())
+			}
+			state.keyframes = append(state.keyframes, keyframe{state.screenPosition, bytepos, state.screen.Clone()})
+		}
+	}
+}
+
+// invalidateScreens forgets every snapshot that depends on the content from position onwards
+func (state *EditorState) invalidateScreens(position int) {
+	if state.screenPosition > position {
+		state.screen = nil
+	}
+	if keep := position/KEYFRAME_INTERVAL + 1; keep < len(state.keyframes) {
+		state.keyframes = state.keyframes[:keep]
+	}
+}
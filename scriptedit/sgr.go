@@ -0,0 +1,368 @@
+package scriptedit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color is a terminal colour: DEFAULT_COLOR, a palette index (0-255) or a 24 bit RGB value.
+type Color int32
+
+const DEFAULT_COLOR Color = -1
+
+const rgbFlag = 1 << 24
+
+func IndexedColor(index int) Color {
+	return Color(index & 0xff)
+}
+
+func RGBColor(r, g, b uint8) Color {
+	return Color(rgbFlag | int32(r)<<16 | int32(g)<<8 | int32(b))
+}
+
+func (c Color) IsDefault() bool {
+	return c == DEFAULT_COLOR
+}
+
+func (c Color) IsRGB() bool {
+	return c >= 0 && c&rgbFlag != 0
+}
+
+func (c Color) RGB() (uint8, uint8, uint8) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+var COLOR_NAMES = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+func (c Color) String() string {
+	switch {
+	case c.IsDefault():
+		return "default"
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	case c < 8:
+		return COLOR_NAMES[c]
+	case c < 16:
+		return "bright " + COLOR_NAMES[c-8]
+	}
+	return fmt.Sprintf("color %d", int(c))
+}
+
+type UnderlineStyle uint8
+
+const (
+	UNDERLINE_NONE UnderlineStyle = iota
+	UNDERLINE_SINGLE
+	UNDERLINE_DOUBLE
+	UNDERLINE_CURLY
+	UNDERLINE_DOTTED
+	UNDERLINE_DASHED
+)
+
+var UNDERLINE_NAMES = []string{"no underline", "underline", "double underline", "curly underline", "dotted underline", "dashed underline"}
+
+// Attr is the graphic rendition of a cell
+type Attr struct {
+	Fg             Color
+	Bg             Color
+	UnderlineColor Color
+	Underline      UnderlineStyle
+	Bold           bool
+	Faint          bool
+	Italic         bool
+	Blink          bool
+	Reverse        bool
+	Hidden         bool
+	Strike         bool
+	Overline       bool
+}
+
+var DEFAULT_ATTR = Attr{Fg: DEFAULT_COLOR, Bg: DEFAULT_COLOR, UnderlineColor: DEFAULT_COLOR}
+
+type SGRKind int
+
+const (
+	SGR_UNKNOWN SGRKind = iota
+	SGR_RESET
+	SGR_BOLD
+	SGR_FAINT
+	SGR_ITALIC
+	SGR_UNDERLINE // the style is in Underline, UNDERLINE_NONE turns it off
+	SGR_BLINK
+	SGR_REVERSE
+	SGR_HIDDEN
+	SGR_STRIKE
+	SGR_OVERLINE
+	SGR_NORMAL_INTENSITY
+	SGR_NOT_ITALIC
+	SGR_NOT_BLINKING
+	SGR_NOT_REVERSED
+	SGR_NOT_HIDDEN
+	SGR_NOT_STRIKE
+	SGR_NOT_OVERLINE
+	SGR_FOREGROUND // the colour is in Color, DEFAULT_COLOR for the default one
+	SGR_BACKGROUND
+	SGR_UNDERLINE_COLOR
+)
+
+// SGRAttribute is one decoded parameter of a "set graphic rendition" sequence.
+type SGRAttribute struct {
+	Kind      SGRKind
+	Underline UnderlineStyle
+	Color     Color
+	Params    string // what it has been decoded from, like "38;2;255;0;0" or "4:3"
+}
+
+var sgrFlags = map[int]SGRKind{
+	0: SGR_RESET, 1: SGR_BOLD, 2: SGR_FAINT, 3: SGR_ITALIC, 5: SGR_BLINK, 6: SGR_BLINK, 7: SGR_REVERSE,
+	8: SGR_HIDDEN, 9: SGR_STRIKE, 22: SGR_NORMAL_INTENSITY, 23: SGR_NOT_ITALIC, 25: SGR_NOT_BLINKING,
+	27: SGR_NOT_REVERSED, 28: SGR_NOT_HIDDEN, 29: SGR_NOT_STRIKE, 53: SGR_OVERLINE, 55: SGR_NOT_OVERLINE,
+}
+
+var sgrColors = map[int]SGRKind{38: SGR_FOREGROUND, 48: SGR_BACKGROUND, 58: SGR_UNDERLINE_COLOR}
+
+// DecodeSGR decodes the parameters of a "set graphic rendition" sequence.
+// It understands both the 38;5;n / 38;2;r;g;b forms and the colon separated
+// sub-parameters (38:2::r:g:b, 4:3...).
+func DecodeSGR(params string) []SGRAttribute {
+	if params == "" {
+		return []SGRAttribute{SGRAttribute{Kind: SGR_RESET, Color: DEFAULT_COLOR}}
+	}
+	groups := strings.Split(params, ";")
+	result := make([]SGRAttribute, 0, len(groups))
+	for i := 0; i < len(groups); i++ {
+		sub := strings.Split(groups[i], ":")
+		code, err := strconv.Atoi(sub[0])
+		if sub[0] == "" {
+			code, err = 0, nil
+		}
+		attr := SGRAttribute{Kind: SGR_UNKNOWN, Color: DEFAULT_COLOR, Params: groups[i]}
+		if kind, ok := sgrFlags[code]; ok && err == nil && len(sub) == 1 {
+			attr.Kind = kind
+		} else if err == nil {
+			switch {
+			case code == 4:
+				attr.Kind = SGR_UNDERLINE
+				attr.Underline = UNDERLINE_SINGLE
+				if len(sub) > 1 {
+					style, _ := strconv.Atoi(sub[1])
+					attr.Underline = UnderlineStyle(style)
+					if attr.Underline > UNDERLINE_DASHED {
+						attr.Kind = SGR_UNKNOWN
+					}
+				}
+			case code == 21:
+				attr.Kind, attr.Underline = SGR_UNDERLINE, UNDERLINE_DOUBLE
+			case code == 24:
+				attr.Kind, attr.Underline = SGR_UNDERLINE, UNDERLINE_NONE
+			case code >= 30 && code <= 37:
+				attr.Kind, attr.Color = SGR_FOREGROUND, IndexedColor(code-30)
+			case code >= 90 && code <= 97:
+				attr.Kind, attr.Color = SGR_FOREGROUND, IndexedColor(code-90+8)
+			case code >= 40 && code <= 47:
+				attr.Kind, attr.Color = SGR_BACKGROUND, IndexedColor(code-40)
+			case code >= 100 && code <= 107:
+				attr.Kind, attr.Color = SGR_BACKGROUND, IndexedColor(code-100+8)
+			case code == 39:
+				attr.Kind = SGR_FOREGROUND
+			case code == 49:
+				attr.Kind = SGR_BACKGROUND
+			case code == 59:
+				attr.Kind = SGR_UNDERLINE_COLOR
+			case sgrColors[code] != SGR_UNKNOWN:
+				var ok bool
+				if len(sub) > 1 {
+					attr.Color, ok = decodeColor(sub[1:], true)
+				} else {
+					// the colour takes the next parameters: 5;n or 2;r;g;b
+					used := len(groups) - i - 1
+					if used > 0 && groups[i+1] == "5" && used >= 2 {
+						used = 2
+					} else if used > 0 && groups[i+1] == "2" && used >= 4 {
+						used = 4
+					}
+					attr.Color, ok = decodeColor(groups[i+1:i+1+used], false)
+					attr.Params = strings.Join(groups[i:i+1+used], ";")
+					i += used
+				}
+				if ok {
+					attr.Kind = sgrColors[code]
+				}
+			}
+		}
+		result = append(result, attr)
+	}
+	return result
+}
+
+// decodeColor decodes what follows 38, 48 or 58: "5", index or "2", red, green, blue.
+// With colons, the RGB form can have a colour space before the components.
+func decodeColor(params []string, colons bool) (Color, bool) {
+	numbers := make([]int, len(params))
+	for i, param := range params {
+		numbers[i], _ = strconv.Atoi(param)
+		if numbers[i] < 0 || numbers[i] > 255 {
+			return DEFAULT_COLOR, false
+		}
+	}
+	switch {
+	case len(numbers) == 2 && numbers[0] == 5:
+		return IndexedColor(numbers[1]), true
+	case len(numbers) == 4 && numbers[0] == 2:
+		return RGBColor(uint8(numbers[1]), uint8(numbers[2]), uint8(numbers[3])), true
+	case colons && len(numbers) >= 5 && numbers[0] == 2:
+		return RGBColor(uint8(numbers[2]), uint8(numbers[3]), uint8(numbers[4])), true
+	}
+	return DEFAULT_COLOR, false
+}
+
+func (a SGRAttribute) String() string {
+	switch a.Kind {
+	case SGR_RESET:
+		return "reset"
+	case SGR_BOLD:
+		return "bold"
+	case SGR_FAINT:
+		return "faint"
+	case SGR_ITALIC:
+		return "italic"
+	case SGR_UNDERLINE:
+		return UNDERLINE_NAMES[a.Underline]
+	case SGR_BLINK:
+		return "blink"
+	case SGR_REVERSE:
+		return "reverse"
+	case SGR_HIDDEN:
+		return "hidden"
+	case SGR_STRIKE:
+		return "strikethrough"
+	case SGR_OVERLINE:
+		return "overline"
+	case SGR_NORMAL_INTENSITY:
+		return "normal intensity"
+	case SGR_NOT_ITALIC:
+		return "not italic"
+	case SGR_NOT_BLINKING:
+		return "not blinking"
+	case SGR_NOT_REVERSED:
+		return "not reversed"
+	case SGR_NOT_HIDDEN:
+		return "not hidden"
+	case SGR_NOT_STRIKE:
+		return "not strikethrough"
+	case SGR_NOT_OVERLINE:
+		return "not overline"
+	case SGR_FOREGROUND:
+		return a.Color.String() + " foreground"
+	case SGR_BACKGROUND:
+		return a.Color.String() + " background"
+	case SGR_UNDERLINE_COLOR:
+		return a.Color.String() + " underline color"
+	}
+	return "unknown " + a.Params
+}
+
+// DescribeSGR explains in plain words the parameters of a "set graphic rendition" sequence.
+func DescribeSGR(params string) string {
+	attrs := DecodeSGR(params)
+	descriptions := make([]string, len(attrs))
+	for i, attr := range attrs {
+		descriptions[i] = attr.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// Apply changes the rendition with decoded attributes
+func (attr *Attr) Apply(attrs []SGRAttribute) {
+	for _, a := range attrs {
+		switch a.Kind {
+		case SGR_RESET:
+			*attr = DEFAULT_ATTR
+		case SGR_BOLD:
+			attr.Bold = true
+		case SGR_FAINT:
+			attr.Faint = true
+		case SGR_ITALIC:
+			attr.Italic = true
+		case SGR_UNDERLINE:
+			attr.Underline = a.Underline
+		case SGR_BLINK:
+			attr.Blink = true
+		case SGR_REVERSE:
+			attr.Reverse = true
+		case SGR_HIDDEN:
+			attr.Hidden = true
+		case SGR_STRIKE:
+			attr.Strike = true
+		case SGR_OVERLINE:
+			attr.Overline = true
+		case SGR_NORMAL_INTENSITY:
+			attr.Bold, attr.Faint = false, false
+		case SGR_NOT_ITALIC:
+			attr.Italic = false
+		case SGR_NOT_BLINKING:
+			attr.Blink = false
+		case SGR_NOT_REVERSED:
+			attr.Reverse = false
+		case SGR_NOT_HIDDEN:
+			attr.Hidden = false
+		case SGR_NOT_STRIKE:
+			attr.Strike = false
+		case SGR_NOT_OVERLINE:
+			attr.Overline = false
+		case SGR_FOREGROUND:
+			attr.Fg = a.Color
+		case SGR_BACKGROUND:
+			attr.Bg = a.Color
+		case SGR_UNDERLINE_COLOR:
+			attr.UnderlineColor = a.Color
+		}
+	}
+}
+
+// SGRString returns the sequence that sets the pen to attr from a reset state
+func (attr Attr) SGRString() string {
+	params := []string{"0"}
+	flags := []struct {
+		set   bool
+		param string
+	}{
+		{attr.Bold, "1"}, {attr.Faint, "2"}, {attr.Italic, "3"}, {attr.Blink, "5"}, {attr.Reverse, "7"},
+		{attr.Hidden, "8"}, {attr.Strike, "9"}, {attr.Overline, "53"},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			params = append(params, flag.param)
+		}
+	}
+	switch attr.Underline {
+	case UNDERLINE_NONE:
+	case UNDERLINE_SINGLE:
+		params = append(params, "4")
+	default:
+		params = append(params, "4:"+strconv.Itoa(int(attr.Underline)))
+	}
+	params = append(params, colorParams(attr.Fg, 30)...)
+	params = append(params, colorParams(attr.Bg, 40)...)
+	params = append(params, colorParams(attr.UnderlineColor, 50)...)
+	return ESC + "[" + strings.Join(params, ";") + "m"
+}
+
+// colorParams returns the SGR parameters setting c (nothing for the default one), base is 30 for
+// the foreground, 40 for the background and 50 for the underline (that only has the extended forms)
+func colorParams(c Color, base int) []string {
+	switch {
+	case c.IsDefault():
+		return nil
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return []string{strconv.Itoa(base + 8), "2", strconv.Itoa(int(r)), strconv.Itoa(int(g)), strconv.Itoa(int(b))}
+	case c < 8 && base != 50:
+		return []string{strconv.Itoa(base + int(c))}
+	case c < 16 && base != 50:
+		return []string{strconv.Itoa(base + 60 + int(c) - 8)}
+	}
+	return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(int(c))}
+}
@@ -0,0 +1,57 @@
+package scriptedit
+
+import (
+	"testing"
+)
+
+type SGRTest struct {
+	params      string
+	description string
+}
+
+var sgrtests = []SGRTest{
+	{"", "reset"},
+	{"0;1;3;31", "reset, bold, italic, red foreground"},
+	{"38;2;255;0;0", "#ff0000 foreground"},
+	{"38:2::255:0:0;48:5:17", "#ff0000 foreground, color 17 background"},
+	{"38:2:0:128:255", "#0080ff foreground"},
+	{"1;38;5;196;4:3;58;5;2", "bold, color 196 foreground, curly underline, green underline color"},
+	{"94;101;21;24;39;49", "bright blue foreground, bright red background, double underline, no underline, default foreground, default background"},
+	{"38;5", "unknown 38;5"},
+	{"12;22", "unknown 12, normal intensity"},
+}
+
+func TestDescribeSGR(t *testing.T) {
+	for _, tt := range sgrtests {
+		if description := DescribeSGR(tt.params); description != tt.description {
+			t.Errorf("%q: wrong description %q", tt.params, description)
+		}
+	}
+}
+
+func TestApplySGR(t *testing.T) {
+	attr := DEFAULT_ATTR
+	attr.Apply(DecodeSGR("1;4:5;38;2;1;2;3;58:5:9;7"))
+	expected := Attr{Fg: RGBColor(1, 2, 3), Bg: DEFAULT_COLOR, UnderlineColor: IndexedColor(9), Underline: UNDERLINE_DASHED, Bold: true, Reverse: true}
+	if attr != expected {
+		t.Errorf("Wrong attributes %+v", attr)
+	}
+	attr.Apply(DecodeSGR("22;24;27;39;59"))
+	if attr != DEFAULT_ATTR {
+		t.Errorf("Wrong attributes %+v", attr)
+	}
+}
+
+func TestSGRString(t *testing.T) {
+	attrs := []string{"1;4:3;38;2;1;2;3;48;5;200;58;5;9", "2;3;5;7;8;9;53;31;102", "4"}
+	for _, params := range attrs {
+		attr := DEFAULT_ATTR
+		attr.Apply(DecodeSGR(params))
+		copy := DEFAULT_ATTR
+		sgr := parse(attr.SGRString())[0]
+		copy.Apply(DecodeSGR(sgr.Params))
+		if copy != attr {
+			t.Errorf("%q: wrong rendering %q", params, attr.SGRString())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package scriptedit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// size of a character cell in the SVG export
+const SVG_CELL_WIDTH = 8
+const SVG_CELL_HEIGHT = 17
+const SVG_FONT_SIZE = 13
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgStyle is what a run of text shares
+type svgStyle struct {
+	fill                                  string
+	bold, italic, underline, strike, over bool
+}
+
+func (style svgStyle) attributes() string {
+	attributes := fmt.Sprintf(` fill="%s"`, style.fill)
+	if style.bold {
+		attributes += ` font-weight="bold"`
+	}
+	if style.italic {
+		attributes += ` font-style="italic"`
+	}
+	var decorations []string
+	if style.underline {
+		decorations = append(decorations, "underline")
+	}
+	if style.strike {
+		decorations = append(decorations, "line-through")
+	}
+	if style.over {
+		decorations = append(decorations, "overline")
+	}
+	if len(decorations) > 0 {
+		attributes += fmt.Sprintf(` text-decoration="%s"`, strings.Join(decorations, " "))
+	}
+	return attributes
+}
+
+// svgScreen renders the content of the screen as SVG elements, the default background being left out.
+func svgScreen(screen *Screen, palette *Palette) string {
+	var buffer bytes.Buffer
+	for y, line := range screen.Cells {
+		top := y * SVG_CELL_HEIGHT
+		// backgrounds first, by runs of the same colour
+		for x := 0; x < len(line); {
+			_, bg := cellColors(screen, x, y, palette)
+			end := x + 1
+			for ; end < len(line); end++ {
+				if _, next := cellColors(screen, end, y, palette); next != bg {
+					break
+				}
+			}
+			if bg != palette.Background {
+				fmt.Fprintf(&buffer, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+					x*SVG_CELL_WIDTH, top, (end-x)*SVG_CELL_WIDTH, SVG_CELL_HEIGHT, hex(bg))
+			}
+			x = end
+		}
+		// then the text, by runs of the same style without their trailing spaces
+		for x := 0; x < len(line); {
+			style := cellStyle(screen, x, y, palette)
+			var text bytes.Buffer
+			length, columns := 0, 0
+			end := x
+			for ; end < len(line) && cellStyle(screen, end, y, palette) == style; end++ {
+				if line[end].Tail {
+					continue
+				}
+				if line[end].Attr.Hidden || line[end].Ch == 0 || line[end].Ch == ' ' {
+					text.WriteRune(' ')
+					continue
+				}
+				text.WriteRune(line[end].Ch)
+				length, columns = text.Len(), end+1-x
+				if end+1 < len(line) && line[end+1].Tail {
+					columns++
+				}
+			}
+			if length > 0 {
+				fmt.Fprintf(&buffer, `<text x="%d" y="%d" textLength="%d"%s>`,
+					x*SVG_CELL_WIDTH, top+SVG_FONT_SIZE, columns*SVG_CELL_WIDTH, style.attributes())
+				xml.EscapeText(&buffer, text.Bytes()[:length])
+				buffer.WriteString("</text>")
+			}
+			x = end
+		}
+	}
+	return buffer.String()
+}
+
+// cellColors returns the colours of a cell, the cursor being drawn in reverse video.
+func cellColors(screen *Screen, x, y int, palette *Palette) (color.RGBA, color.RGBA) {
+	fg, bg := palette.Colors(screen.Cells[y][x].Attr)
+	if screen.CursorVisible && x == screen.X && y == screen.Y {
+		return bg, fg
+	}
+	return fg, bg
+}
+
+func cellStyle(screen *Screen, x, y int, palette *Palette) svgStyle {
+	attr := screen.Cells[y][x].Attr
+	fg, _ := cellColors(screen, x, y, palette)
+	underline := attr.Underline != UNDERLINE_NONE
+	return svgStyle{hex(fg), attr.Bold, attr.Italic, underline, attr.Strike, attr.Overline}
+}
+
+// WriteSVG renders the recording as a self-contained animated SVG. The frames are stacked
+// vertically and a CSS animation moves them in front of the terminal; identical frames are
+// stored once.
+func (state *EditorState) WriteSVG(writer io.Writer, palette *Palette) error {
+	width, height := state.TerminalSize()
+	frameHeight := height * SVG_CELL_HEIGHT
+
+	var definitions []string
+	ids := make(map[string]int)
+	var timeline []int // the definition displayed by each step
+	var starts []float32
+	var total float32
+	err := state.Frames(func(frame Frame) error {
+		content := svgScreen(frame.Screen, palette)
+		id, known := ids[content]
+		if !known {
+			id = len(definitions)
+			ids[content] = id
+			definitions = append(definitions, content)
+		}
+		if len(timeline) > 0 && timeline[len(timeline)-1] == id {
+			total += frame.Duration
+			return nil
+		}
+		if len(timeline) == 0 {
+			// the first frame is displayed from the start
+			total = frame.Time
+			starts = append(starts, 0)
+		} else {
+			starts = append(starts, frame.Time)
+		}
+		timeline = append(timeline, id)
+		total += frame.Duration
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="%d">`+"\n",
+		width*SVG_CELL_WIDTH, frameHeight, width*SVG_CELL_WIDTH, frameHeight, SVG_FONT_SIZE)
+	buffer.WriteString("<style>\ntext { white-space: pre; }\n")
+	if len(timeline) > 1 {
+		fmt.Fprintf(&buffer, "#strip { animation: play %.3fs step-end infinite; }\n@keyframes play {\n", total)
+		for step, start := range starts {
+			fmt.Fprintf(&buffer, "  %.3f%% { transform: translateY(%dpx); }\n", 100*start/total, -step*frameHeight)
+		}
+		buffer.WriteString("}\n")
+	}
+	buffer.WriteString("</style>\n")
+	fmt.Fprintf(&buffer, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n<defs>\n", hex(palette.Background))
+	for id, content := range definitions {
+		fmt.Fprintf(&buffer, `<g id="f%d">%s</g>`+"\n", id, content)
+	}
+	buffer.WriteString("</defs>\n<g id=\"strip\">\n")
+	for step, id := range timeline {
+		fmt.Fprintf(&buffer, `<use xlink:href="#f%d" y="%d"/>`+"\n", id, step*frameHeight)
+	}
+	buffer.WriteString("</g>\n</svg>\n")
+	_, err = writer.Write(buffer.Bytes())
+	return err
+}
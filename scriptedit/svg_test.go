@@ -0,0 +1,48 @@
+package scriptedit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	// a, a<b in red, back to a, then nothing new
+	state := recording(t, "\033[?25la\033[31m<b\033[0m\b\b  \033[1;2H", []Timing{Timing{1, 7}, Timing{1, 10}, Timing{1, 9}, Timing{1, 6}})
+	var output bytes.Buffer
+	if err := state.WriteSVG(&output, &XTERM_PALETTE); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	svg := output.String()
+
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Invalid SVG %s\n%s", err, svg)
+		}
+	}
+	if strings.Count(svg, `<g id="f`) != 2 || strings.Count(svg, "<use ") != 3 {
+		t.Errorf("The identical frames should be shared\n%s", svg)
+	}
+	if !strings.Contains(svg, `<text x="8" y="13" textLength="16" fill="#cd0000">&lt;b</text>`) {
+		t.Errorf("Wrong coloured text\n%s", svg)
+	}
+	if !strings.Contains(svg, `width="80" height="51"`) {
+		t.Errorf("Wrong terminal size\n%s", svg)
+	}
+	if !strings.Contains(svg, "animation: play 6.000s") || !strings.Contains(svg, "33.333% { transform: translateY(-51px); }") {
+		t.Errorf("Wrong animation\n%s", svg)
+	}
+}
+
+func TestSVGCursor(t *testing.T) {
+	screen := NewScreen(4, 1)
+	screen.Feed(parse("ab"))
+	if svg := svgScreen(screen, &XTERM_PALETTE); !strings.Contains(svg, `<rect x="16" y="0" width="8" height="17" fill="#e5e5e5"/>`) {
+		t.Errorf("The cursor should be drawn\n%s", svg)
+	}
+}
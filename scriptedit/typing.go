@@ -0,0 +1,72 @@
+package scriptedit
+
+import (
+	"bytes"
+	"math/rand"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MIN_TYPING_RUN = 2 // keystrokes in a row before it is considered as typing
+
+// isKeystroke tells if a chunk looks like the echo of a single typed character.
+func isKeystroke(data []byte) bool {
+	r, size := utf8.DecodeRune(data)
+	return size > 0 && size == len(data) && r != utf8.RuneError && unicode.IsPrint(r)
+}
+
+// RetimeTyping finds the runs of chunks echoing a single character, in the In/Out selection or
+// everywhere, and replaces the delays between their keystrokes by interval seconds, randomly
+// varied by up to jitter times interval. The delay before the first keystroke of a run, the pause
+// after the prompt, is kept as the output of the commands. The same seed gives the same result.
+// It returns how many keystrokes were retimed.
+func (state *EditorState) RetimeTyping(interval, jitter float32, seed int64) int {
+	var raw bytes.Buffer
+	for _, ansi := range state.Content {
+		raw.WriteString(ansi.String())
+	}
+	content := raw.Bytes()
+	random := rand.New(rand.NewSource(seed))
+	first, last := state.selectedTimings()
+
+	var offset int
+	for _, t := range state.Timings[:first] {
+		offset += t.Length
+	}
+	keystroke := make([]bool, last-first)
+	for i := first; i < last; i++ {
+		end := offset + state.Timings[i].Length
+		if end <= len(content) {
+			keystroke[i-first] = isKeystroke(content[offset:end])
+		}
+		offset = end
+	}
+
+	oldTimings := append([]Timing(nil), state.Timings...)
+	changed := 0
+	for start := 0; start < len(keystroke); {
+		end := start
+		for end < len(keystroke) && keystroke[end] {
+			end++
+		}
+		if end-start >= MIN_TYPING_RUN {
+			for i := start + 1; i < end; i++ {
+				delay := interval * (1 + jitter*(2*random.Float32()-1))
+				if delay < 0 {
+					delay = 0
+				}
+				state.Timings[first+i].Time = delay
+				changed++
+			}
+		}
+		if end == start {
+			end++
+		}
+		start = end
+	}
+	if changed > 0 {
+		from, to := state.selection()
+		state.record(state.Position, nil, nil, oldTimings, Operation{Name: OP_TYPING, From: from, To: to, Seconds: interval, Jitter: jitter, Seed: seed})
+	}
+	return changed
+}
@@ -4,43 +4,14 @@ import (
 	"strings"
 	"fmt"
 	"bytes"
-	"time"
+	"unicode/utf8"
 )
 
 const STATUS_POS = 43
 const WIDTH = 132
+const HEIGHT = 43 // size of the recorded terminal, see record.sh
 const POINTER = WIDTH/2
-
-func (ttyfd TTY) readCursorPosition() (int, int) {
-	ttyfd.write(READ_CURSOR_POSITION)
-	var chr byte
-	var nb []byte = make([]byte, 0)
-	var x, y int
-
-	chr, _, _ = ttyfd.Readchr()
-	if chr != ESC_CHR {
-		return 0, 0 // something failed !
-	}
-	chr, _, _ = ttyfd.Readchr()
-	if chr != '[' {
-		return 0, 0 // something failed !
-	}
-
-	for chr != ';' {
-		chr, _, _ = ttyfd.Readchr()
-		nb = append(nb, chr)
-	}
-	fmt.Sscanf(string(nb), "%d", &x)
-	nb = make([]byte, 0)
-	for chr != 'R' {
-		chr, _, _ = ttyfd.Readchr()
-		nb = append(nb, chr)
-	}
-	fmt.Sscanf(string(nb), "%d", &y)
-	return x, y
-
-}
-
+const MAX_EXPLANATION = 36 // what fits between the time and the cursor position
 
 func (ttyfd TTY) writeTicker(state *EditorState) {
 	left := state.Position - POINTER
@@ -92,36 +63,28 @@ func (ttyfd TTY) navBar(state *EditorState) {
 }
 
 func (ttyfd TTY) JumpToNextSameCursorPosition(state *EditorState) bool {
-	initx, inity := ttyfd.readCursorPosition()
-	position := state.Position
-	for position < len(state.Content) {
-		ttyfd.write(state.Content[position].String())
-		position++
-		x, y := ttyfd.readCursorPosition()
-		if x == initx && y == inity {
-			state.Position = position
-			return true
-		}
+	from := state.Position
+	if !state.NextSameCursorPosition() {
+		return false
+	}
+	for _, ansi := range (state.Content[from:state.Position]) {
+		ttyfd.write(ansi.String())
 	}
-	return false
+	return true
 }
 
 func (ttyfd TTY) WriteStatus(state *EditorState) {
-	x, y := ttyfd.readCursorPosition()
+	x, y := state.ScreenAt(state.Position).Cursor()
 	ttyfd.write(RESET_COLOR)
 	ttyfd.navBar(state)
-	var explanation string
-	currentAnsi := state.Content[state.Position]
-	if currentAnsi.Code != nil {
-		explanation = currentAnsi.Code.Explanation
-	} else {
-		explanation = fmt.Sprintf("Character %c (%x)", EdulcorateCharacter(currentAnsi.Letter), currentAnsi.Letter)
+	explanation := "End"
+	if state.Position < len(state.Content) {
+		explanation = state.Content[state.Position].Explain()
 	}
-
-	if currentAnsi.Params != "" {
-		explanation += " (" + currentAnsi.Params + ")"
+	if runes := []rune(explanation); len(runes) > MAX_EXPLANATION {
+		explanation = string(runes[:MAX_EXPLANATION - 1]) + "…"
 	}
-	leftExplanation := POINTER - len(explanation)/2
+	leftExplanation := POINTER - utf8.RuneCountInString(explanation)/2
 
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 3, leftExplanation))
 	ttyfd.write("| " + explanation + " |")
@@ -132,19 +95,54 @@ func (ttyfd TTY) WriteStatus(state *EditorState) {
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 3, 23))
 	ttyfd.write(fmt.Sprintf("Time   %.2f / %.2f s", state.Time, state.Total_time))
 
+	if index := state.CurrentTiming(); index >= 0 {
+		ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 3, 100))
+		ttyfd.write(fmt.Sprintf("Delay %7.3f s", state.Timings[index].Time))
+	}
+
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 3, 123))
 	ttyfd.write(fmt.Sprintf("Cur %dx%d", x, y))
 
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 5, 0))
 	ttyfd.write(fmt.Sprintf("         [←] : reverse       [→] : forward         [SPACE] : Play/Pause       [i] : IN mark         [o] : OUT mark        [d] : del"))
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 6, 0))
-	ttyfd.write(fmt.Sprintf("[CTRL] + [←] : rw   [CTRL] + [→] : ff                                         [n] : smart extend    [s] : SAVE            [q] : quit"))
+	ttyfd.write(fmt.Sprintf("[CTRL]+[←] : rw     [CTRL]+[→] : ff           [u] : undo  [r] : redo     [n] : smart extend     [s] : SAVE  [?] : help  [q] : quit"))
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, x, y))
 }
 
+// the keys of the editor, all of them
+var HELP = []string{
+	"[←] [→]              previous / next command      [CTRL] + [←] [→]   previous / next chunk",
+	"[SPACE]              play / pause                 [n]                extend the selection to the same cursor position",
+	"[i] [o]              IN / OUT mark                [ESC] [ESC]        clear the selection",
+	"[DEL]                delete the selection or the command under the cursor",
+	"[c] [x] [v]          copy / cut / paste the selection with its timings",
+	"[a]                  type text at the cursor until [ESC]",
+	"[[] []] [z]          shorten / lengthen / remove the delay of the chunk",
+	"[t] [p]              set the delay of the chunk / insert a pause before the cursor",
+	"[l]                  limit the pauses of the selection, or all of them",
+	"[+] [-]              play the selection faster / slower",
+	"[m]                  add or remove a chapter",
+	"[u] [r]              undo / redo",
+	"[s] [w]              save the edits in the project / save as",
+	"[q]                  quit",
+	"",
+	"Press any key to go back to the recording",
+}
+
+// Help lists all the keys in place of the recording, until it is redrawn.
+func (ttyfd TTY) Help() {
+	ttyfd.write(RESET_COLOR)
+	ttyfd.write(CLEAR_SCREEN)
+	for i, line := range HELP {
+		ttyfd.write(fmt.Sprintf(MOVE_CURSOR, i + 2, 3))
+		ttyfd.write(line)
+	}
+}
+
 func (ttyfd TTY) Notify(message string) {
 	ttyfd.write(fmt.Sprintf(MOVE_CURSOR, STATUS_POS + 3, 20))
-	ttyfd.write(fmt.Sprintf(message))
+	ttyfd.write(message)
 
 }
 
@@ -153,10 +151,13 @@ func (ttyfd TTY) Redraw(state *EditorState) {
 	ttyfd.write(CLEAR_SCREEN)
 	var buffer bytes.Buffer
 
-	for _, ansi := range (state.Content[0:state.Position]) {
+	// restore the closest keyframe and only replay the tail from there
+	position, bytepos, screen := state.KeyframeBefore(state.Position)
+	ttyfd.write(screen.Render())
+	for _, ansi := range (state.Content[position:state.Position]) {
 		buffer.WriteString(ansi.String())
 	}
-	state.Bytepos = buffer.Len()
+	state.Bytepos = bytepos + buffer.Len()
 	ttyfd.write(buffer.String())
 	ttyfd.WriteStatus(state)
 }
@@ -172,35 +173,10 @@ func (ttyfd TTY) Restore() {
 	ttyfd.write(CLEAR_SCREEN)
 }
 
-var playTime int64
-var refTime int64
-var playTimeIndex int
-
-func (ttyfd TTY) PlayingPoll(state *EditorState) {
-	var nbSecDone int64 = time.Now().UnixNano() - refTime
-	bytesToPlay := 0
-	for playTime < nbSecDone {
-		bytesToPlay += state.Timings[playTimeIndex].Length
-		playTime += int64(float64(state.Timings[playTimeIndex].Time)*1000000000)
-		playTimeIndex++
-	}
-	chunkToDisplay := ""
-	for len(chunkToDisplay) < bytesToPlay {
-		chunkToDisplay += state.Content[state.Position].String()
-		state.Position++
-	}
-
-	ttyfd.write(chunkToDisplay)
-	if state.Timings[playTimeIndex].Time > .250 {
-		time.Sleep(250)
-	} else {
-		time.Sleep(time.Duration(state.Timings[playTimeIndex].Time)*time.Millisecond)
-	}
-
-}
-
-func (ttyfd TTY) StartPlaying(state *EditorState) {
-	playTime = 0
-	refTime = time.Now().UnixNano()
-	playTimeIndex, _, _ = state.deduceTiming(state.Bytepos)
+// Play displays a chunk sent by a Scheduler and moves the state where it ends.
+func (ttyfd TTY) Play(state *EditorState, chunk Chunk) {
+	ttyfd.write(string(chunk.Data))
+	state.Position = chunk.Position
+	state.Bytepos = chunk.Bytepos
+	_, _, state.Time = state.deduceTiming(state.Bytepos)
 }